@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// A Credential provides a credential, such as a token or a password, each time
+// a registration request is decorated.  The String() method must never reveal
+// the credential.
+type Credential interface {
+	fmt.Stringer
+	Credential(context.Context) (string, error)
+}
+
+// StaticCredential returns a Credential that always provides the value.
+func StaticCredential(value string) Credential {
+	return staticCredential(value)
+}
+
+type staticCredential string
+
+func (s staticCredential) Credential(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func (s staticCredential) String() string {
+	return "***"
+}
+
+// FileCredential returns a Credential that provides the contents of the file
+// with the surrounding whitespace removed.  The file is read again whenever it
+// changes, so the credential can be rotated without restarting.
+func FileCredential(path string) Credential {
+	load := func() (string, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	return &fileCredential{
+		path:  path,
+		files: newWatchedFiles(load, path),
+	}
+}
+
+type fileCredential struct {
+	path  string
+	files *watchedFiles[string]
+}
+
+func (f *fileCredential) Credential(context.Context) (string, error) {
+	return f.files.get()
+}
+
+func (f *fileCredential) String() string {
+	return "File(" + f.path + ")"
+}
+
+// The CredentialFunc type is an adapter to allow the use of ordinary functions
+// as credentials.  If f is a function with the appropriate signature,
+// CredentialFunc(f) is a Credential that calls f.
+type CredentialFunc func(context.Context) (string, error)
+
+func (c CredentialFunc) Credential(ctx context.Context) (string, error) {
+	return c(ctx)
+}
+
+func (c CredentialFunc) String() string {
+	return "CredentialFunc(fn)"
+}
+
+// BearerAuth returns a Decorator that adds the token to the Authorization
+// header of the registration requests.  The token is obtained for each
+// request.  An empty token is an error.
+func BearerAuth(token Credential) Decorator {
+	return &bearerAuth{
+		token: token,
+	}
+}
+
+type bearerAuth struct {
+	token Credential
+}
+
+func (b bearerAuth) Decorate(r *http.Request) error {
+	if b.token == nil {
+		return fmt.Errorf("%w: no token provided", ErrDecoratorFailed)
+	}
+
+	token, err := b.token.Credential(r.Context())
+	if err != nil {
+		return fmt.Errorf("%w: unable to get the token: %w", ErrDecoratorFailed, err)
+	}
+	if token == "" {
+		return fmt.Errorf("%w: the token is empty", ErrDecoratorFailed)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (b bearerAuth) String() string {
+	return "BearerAuth(***)"
+}
+
+// BasicAuth returns a Decorator that adds the username and password to the
+// Authorization header of the registration requests.  The password is obtained
+// for each request.  If the password is nil, an empty password is used.
+func BasicAuth(username string, password Credential) Decorator {
+	return &basicAuth{
+		username: username,
+		password: password,
+	}
+}
+
+type basicAuth struct {
+	username string
+	password Credential
+}
+
+func (b basicAuth) Decorate(r *http.Request) error {
+	if b.username == "" {
+		return fmt.Errorf("%w: username is required", ErrDecoratorFailed)
+	}
+
+	var password string
+	if b.password != nil {
+		var err error
+		password, err = b.password.Credential(r.Context())
+		if err != nil {
+			return fmt.Errorf("%w: unable to get the password: %w", ErrDecoratorFailed, err)
+		}
+	}
+
+	r.SetBasicAuth(b.username, password)
+	return nil
+}
+
+func (b basicAuth) String() string {
+	return "BasicAuth(" + b.username + ", ***)"
+}
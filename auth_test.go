@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnknown = errors.New("unknown error")
+
+func TestFileCredential(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(os.WriteFile(path, []byte(" first\n"), 0600))
+
+	c := FileCredential(path)
+	assert.Equal("File("+path+")", c.String())
+
+	got, err := c.Credential(context.Background())
+	assert.NoError(err)
+	assert.Equal("first", got)
+
+	// The file is read again when it changes.
+	require.NoError(os.WriteFile(path, []byte("second"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(os.Chtimes(path, later, later))
+
+	got, err = c.Credential(context.Background())
+	assert.NoError(err)
+	assert.Equal("second", got)
+
+	require.NoError(os.Remove(path))
+	_, err = c.Credential(context.Background())
+	assert.Error(err)
+}
+
+func TestAuthDecorators(t *testing.T) {
+	failing := CredentialFunc(func(context.Context) (string, error) {
+		return "", errUnknown
+	})
+
+	tests := []struct {
+		description string
+		d           Decorator
+		str         string
+		user        string
+		pass        string
+		bearer      string
+		expectedErr error
+	}{
+		{
+			description: "bearer",
+			d:           BearerAuth(StaticCredential("token")),
+			str:         "BearerAuth(***)",
+			bearer:      "Bearer token",
+		}, {
+			description: "bearer from a callback",
+			d: BearerAuth(CredentialFunc(func(context.Context) (string, error) {
+				return "token", nil
+			})),
+			str:    "BearerAuth(***)",
+			bearer: "Bearer token",
+		}, {
+			description: "bearer with an empty token",
+			d:           BearerAuth(StaticCredential("")),
+			str:         "BearerAuth(***)",
+			expectedErr: ErrDecoratorFailed,
+		}, {
+			description: "bearer with a nil token",
+			d:           BearerAuth(nil),
+			str:         "BearerAuth(***)",
+			expectedErr: ErrDecoratorFailed,
+		}, {
+			description: "bearer with a failing token",
+			d:           BearerAuth(failing),
+			str:         "BearerAuth(***)",
+			expectedErr: errUnknown,
+		}, {
+			description: "basic",
+			d:           BasicAuth("user", StaticCredential("pass")),
+			str:         "BasicAuth(user, ***)",
+			user:        "user",
+			pass:        "pass",
+		}, {
+			description: "basic with a nil password",
+			d:           BasicAuth("user", nil),
+			str:         "BasicAuth(user, ***)",
+			user:        "user",
+		}, {
+			description: "basic without a username",
+			d:           BasicAuth("", StaticCredential("pass")),
+			str:         "BasicAuth(, ***)",
+			expectedErr: ErrDecoratorFailed,
+		}, {
+			description: "basic with a failing password",
+			d:           BasicAuth("user", failing),
+			str:         "BasicAuth(user, ***)",
+			expectedErr: errUnknown,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			assert.Equal(tc.str, tc.d.String())
+			assert.NotContains(tc.d.String(), "token")
+			assert.NotContains(tc.d.String(), "pass")
+
+			r, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+			err := tc.d.Decorate(r)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.ErrorIs(err, ErrDecoratorFailed)
+				assert.Empty(r.Header.Get("Authorization"))
+				return
+			}
+			assert.NoError(err)
+
+			if tc.bearer != "" {
+				assert.Equal(tc.bearer, r.Header.Get("Authorization"))
+				return
+			}
+			user, pass, ok := r.BasicAuth()
+			assert.True(ok)
+			assert.Equal(tc.user, user)
+			assert.Equal(tc.pass, pass)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// certReloader provides a client certificate that is loaded again whenever the
+// certificate or key file changes.  If loading the changed files fails, the
+// previous certificate continues to be used and the failure is reported once.
+type certReloader struct {
+	files *watchedFiles[*tls.Certificate]
+
+	m       sync.Mutex
+	onError func(error)
+}
+
+// newCertReloader creates a certReloader and loads the certificate.  The
+// onError function is called with any error loading changed files and may be
+// nil.
+func newCertReloader(certFile, keyFile string, onError func(error)) (*certReloader, error) {
+	load := func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}
+
+	cr := certReloader{
+		files:   newWatchedFiles(load, certFile, keyFile),
+		onError: onError,
+	}
+
+	if _, err := cr.files.get(); err != nil {
+		return nil, err
+	}
+
+	return &cr, nil
+}
+
+// notify sets the function called with any error loading changed files.
+func (cr *certReloader) notify(onError func(error)) {
+	cr.m.Lock()
+	defer cr.m.Unlock()
+
+	cr.onError = onError
+}
+
+// GetClientCertificate returns the current certificate, reloading it first if
+// the files changed.  It is used as the tls.Config.GetClientCertificate.
+func (cr *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := cr.files.get()
+	if err != nil {
+		cr.m.Lock()
+		onError := cr.onError
+		cr.m.Unlock()
+
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	return cert, nil
+}
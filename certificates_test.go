@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// testCA is a certificate authority used to issue test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	require := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	require.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue creates a certificate and key signed by the CA and returns them PEM
+// encoded.
+func (ca *testCA) issue(t *testing.T, tmpl x509.Certificate) ([]byte, []byte) {
+	require := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(err)
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if tmpl.ExtKeyUsage == nil {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes the file and moves the modification time forward so the
+// change is detected even on file systems with a coarse time resolution.
+func writeFile(t *testing.T, path string, data []byte, mod time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, mod, mod))
+}
+
+func TestClientCertificates(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	mod := time.Now()
+	cert, key := ca.issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "first"}})
+	writeFile(t, certFile, cert, mod)
+	writeFile(t, keyFile, key, mod)
+	writeFile(t, caFile, ca.pem, mod)
+
+	var m sync.Mutex
+	var clients []string
+
+	server := httptest.NewUnstartedServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				m.Lock()
+				clients = append(clients, r.TLS.PeerCertificates[0].Subject.CommonName)
+				m.Unlock()
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	serverCert, serverKey := ca.issue(t, x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{server.Listener.Addr().(*net.TCPAddr).IP},
+	})
+	pair, err := tls.X509KeyPair(serverCert, serverKey)
+	require.NoError(err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{pair},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	var events []event.Registration
+	whl, err := New(server.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		ClientCertificates(certFile, keyFile, caFile),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NoError(err)
+
+	// Close the idle connections so each registration performs a handshake.
+	register := func() {
+		require.NoError(whl.Register(context.Background()))
+		whl.client.CloseIdleConnections()
+	}
+
+	register()
+	assert.Equal([]string{"first"}, clients)
+
+	// The rotated certificate is used without restarting the listener.
+	mod = mod.Add(time.Minute)
+	cert, key = ca.issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "second"}})
+	writeFile(t, certFile, cert, mod)
+	writeFile(t, keyFile, key, mod)
+
+	register()
+	assert.Equal([]string{"first", "second"}, clients)
+
+	// A certificate that fails to load is reported and the previous
+	// certificate is used.
+	mod = mod.Add(time.Minute)
+	writeFile(t, keyFile, []byte("invalid"), mod)
+
+	events = nil
+	register()
+	assert.Equal([]string{"first", "second", "second"}, clients)
+	require.Len(events, 2)
+	assert.ErrorIs(events[0].Err, ErrCertificateReloadFailed)
+	assert.NoError(events[1].Err)
+
+	// The failure is only reported once until the files change again.
+	events = nil
+	register()
+	assert.Equal([]string{"first", "second", "second", "second"}, clients)
+	require.Len(events, 1)
+	assert.NoError(events[0].Err)
+}
+
+func TestClientCertificates_Errors(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	invalid := filepath.Join(dir, "invalid.pem")
+
+	cert, key := ca.issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "client"}})
+	writeFile(t, certFile, cert, time.Now())
+	writeFile(t, keyFile, key, time.Now())
+	writeFile(t, caFile, ca.pem, time.Now())
+	writeFile(t, invalid, []byte("invalid"), time.Now())
+
+	tests := []newTest{
+		{
+			description: "assert valid files work",
+			r:           validWHR,
+			opt:         ClientCertificates(certFile, keyFile, caFile),
+			check: func(assert *assert.Assertions, l *Listener) {
+				transport, ok := l.client.Transport.(*http.Transport)
+				if assert.True(ok) {
+					assert.NotNil(transport.TLSClientConfig.GetClientCertificate)
+					assert.NotNil(transport.TLSClientConfig.RootCAs)
+				}
+			},
+		}, {
+			description: "assert missing files error",
+			r:           validWHR,
+			opt:         ClientCertificates("", keyFile),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid key errors",
+			r:           validWHR,
+			opt:         ClientCertificates(certFile, invalid),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert a missing ca file errors",
+			r:           validWHR,
+			opt:         ClientCertificates(certFile, keyFile, filepath.Join(dir, "missing.pem")),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid ca file errors",
+			r:           validWHR,
+			opt:         ClientCertificates(certFile, keyFile, invalid),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an http client cannot be used as well",
+			r:           validWHR,
+			opts: []Option{
+				HTTPClient(nil),
+				ClientCertificates(certFile, keyFile),
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+}
@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CertificateAlgorithm is the token type returned by Tokenize() when the
+// callback has no usable signature and is authorized by the client
+// certificate instead.
+const CertificateAlgorithm = "certificate"
+
+// CertificatePolicy describes the client certificates allowed to deliver
+// webhook callbacks over mutual TLS.  A certificate is allowed if it matches
+// any of the subjects, SANs or SPKI pins.
+//
+// Subjects and SANs are only matched if the server verified the certificate
+// chain, for example using tls.RequireAndVerifyClientCert.  SPKI pins are
+// matched even if the chain was not verified, since the client proves it holds
+// the private key during the handshake.
+type CertificatePolicy struct {
+	// Subjects are the allowed subjects.  Each is matched against the common
+	// name and the full distinguished name of the certificate subject.
+	Subjects []string
+
+	// SANs are the allowed subject alternative names.  Each is matched
+	// against the DNS names, email addresses, IP addresses and URIs of the
+	// certificate.
+	SANs []string
+
+	// SPKIPins are the allowed base64 encoded SHA-256 hashes of the subject
+	// public key info of the certificate.
+	SPKIPins []string
+
+	// RequireSignature requires the callback to have both an allowed client
+	// certificate and a valid signature.  Otherwise either is sufficient.
+	RequireSignature bool
+}
+
+// AcceptClientCertificates is an option that enables authorizing webhook
+// callbacks based on the client certificate presented over mutual TLS.  Unless
+// the policy requires a signature, callbacks without a signature header are
+// authorized by the client certificate alone.  See CertificatePolicy for
+// details.
+func AcceptClientCertificates(p CertificatePolicy) Option {
+	return &acceptClientCertificatesOption{
+		policy: p,
+	}
+}
+
+type acceptClientCertificatesOption struct {
+	policy CertificatePolicy
+}
+
+func (a acceptClientCertificatesOption) apply(lis *Listener) error {
+	p := a.policy
+	if len(p.Subjects)+len(p.SANs)+len(p.SPKIPins) == 0 {
+		return fmt.Errorf("%w: a subject, SAN or SPKI pin is required", ErrInput)
+	}
+
+	for _, pin := range p.SPKIPins {
+		b, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("%w: invalid SPKI pin '%s'", ErrInput, pin)
+		}
+	}
+
+	lis.certPolicy = &p
+	return nil
+}
+
+func (a acceptClientCertificatesOption) String() string {
+	var parts []string
+	if len(a.policy.Subjects) > 0 {
+		parts = append(parts, "Subjects("+strings.Join(a.policy.Subjects, ", ")+")")
+	}
+	if len(a.policy.SANs) > 0 {
+		parts = append(parts, "SANs("+strings.Join(a.policy.SANs, ", ")+")")
+	}
+	if len(a.policy.SPKIPins) > 0 {
+		parts = append(parts, "SPKIPins("+strings.Join(a.policy.SPKIPins, ", ")+")")
+	}
+	if a.policy.RequireSignature {
+		parts = append(parts, "RequireSignature")
+	}
+	return "AcceptClientCertificates(" + strings.Join(parts, ", ") + ")"
+}
+
+// SPKIPin returns the pin of the certificate for use in a CertificatePolicy.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// allows returns the subject of the client certificate of the request if it is
+// allowed by the policy.
+func (p *CertificatePolicy) allows(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", fmt.Errorf("%w: no client certificate", ErrInvalidClientCertificate)
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	subject := cert.Subject.String()
+
+	pin := SPKIPin(cert)
+	for _, want := range p.SPKIPins {
+		if want == pin {
+			return subject, nil
+		}
+	}
+
+	if len(r.TLS.VerifiedChains) == 0 {
+		return subject, fmt.Errorf("%w: '%s' is not pinned and was not verified",
+			ErrInvalidClientCertificate, subject)
+	}
+
+	for _, want := range p.Subjects {
+		if want == cert.Subject.CommonName || want == subject {
+			return subject, nil
+		}
+	}
+
+	for _, want := range p.SANs {
+		if sanMatches(cert, want) {
+			return subject, nil
+		}
+	}
+
+	return subject, fmt.Errorf("%w: '%s' is not allowed", ErrInvalidClientCertificate, subject)
+}
+
+func sanMatches(cert *x509.Certificate, want string) bool {
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, want) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if strings.EqualFold(email, want) {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == want {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == want {
+			return true
+		}
+	}
+	return false
+}
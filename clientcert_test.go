@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptClientCertificates(t *testing.T) {
+	const body = "body"
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, x509.Certificate{
+		Subject:        pkix.Name{CommonName: "sender", Organization: []string{"example"}},
+		DNSNames:       []string{"sender.example.com"},
+		EmailAddresses: []string{"sender@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/sender"}},
+	})
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	verified := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert, ca.cert}},
+	}
+	unverified := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+	}
+
+	tests := []struct {
+		description string
+		policy      CertificatePolicy
+		state       *tls.ConnectionState
+		signature   string
+		tokenErr    error
+		expectedErr error
+		alg         string
+	}{
+		{
+			description: "common name",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "distinguished name",
+			policy:      CertificatePolicy{Subjects: []string{"CN=sender,O=example"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "dns san",
+			policy:      CertificatePolicy{SANs: []string{"SENDER.example.com"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "email san",
+			policy:      CertificatePolicy{SANs: []string{"sender@example.com"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "ip san",
+			policy:      CertificatePolicy{SANs: []string{"10.0.0.1"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "uri san",
+			policy:      CertificatePolicy{SANs: []string{"spiffe://example.com/sender"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "spki pin without verification",
+			policy:      CertificatePolicy{SPKIPins: []string{SPKIPin(cert)}},
+			state:       unverified,
+			alg:         CertificateAlgorithm,
+		}, {
+			description: "subject without verification",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}},
+			state:       unverified,
+			alg:         CertificateAlgorithm,
+			expectedErr: ErrInvalidClientCertificate,
+		}, {
+			description: "subject not allowed",
+			policy:      CertificatePolicy{Subjects: []string{"other"}},
+			state:       verified,
+			alg:         CertificateAlgorithm,
+			expectedErr: ErrInvalidClientCertificate,
+		}, {
+			description: "no client certificate",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}},
+			alg:         CertificateAlgorithm,
+			expectedErr: ErrInvalidClientCertificate,
+		}, {
+			description: "signature without a certificate",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}},
+			signature:   signature,
+			alg:         "sha256",
+		}, {
+			description: "signature and certificate required",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}, RequireSignature: true},
+			state:       verified,
+			signature:   signature,
+			alg:         "sha256",
+		}, {
+			description: "signature and certificate required, no certificate",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}, RequireSignature: true},
+			signature:   signature,
+			alg:         "sha256",
+			expectedErr: ErrInvalidClientCertificate,
+		}, {
+			description: "signature and certificate required, no signature",
+			policy:      CertificatePolicy{Subjects: []string{"sender"}, RequireSignature: true},
+			state:       verified,
+			tokenErr:    ErrAlgorithmNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			l, err := New("http://example.com", &validWHR,
+				AcceptSHA256(),
+				AcceptedSecrets("secret"),
+				AcceptClientCertificates(tc.policy),
+			)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.TLS = tc.state
+			if tc.signature != "" {
+				r.Header.Set(xmidtHeader, tc.signature)
+			}
+
+			token, err := l.Tokenize(r)
+			if tc.tokenErr != nil {
+				assert.ErrorIs(err, tc.tokenErr)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.alg, token.Type())
+
+			match, err := l.AuthorizeMatch(r, token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(match)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.alg, match.Algorithm)
+			if tc.state != nil {
+				assert.Equal("CN=sender,O=example", match.ClientCertificate)
+			}
+		})
+	}
+}
+
+func TestAcceptClientCertificatesOption(t *testing.T) {
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	tests := []newTest{
+		{
+			description: "assert a policy works",
+			r:           validWHR,
+			opt:         AcceptClientCertificates(CertificatePolicy{SPKIPins: []string{pin}}),
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal([]string{pin}, l.certPolicy.SPKIPins)
+			},
+		}, {
+			description: "assert an empty policy errors",
+			r:           validWHR,
+			opt:         AcceptClientCertificates(CertificatePolicy{RequireSignature: true}),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid pin errors",
+			r:           validWHR,
+			opt:         AcceptClientCertificates(CertificatePolicy{SPKIPins: []string{"abc"}}),
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+
+	assert.Equal(t,
+		"AcceptClientCertificates(Subjects(a), SANs(b), SPKIPins("+pin+"), RequireSignature)",
+		AcceptClientCertificates(CertificatePolicy{
+			Subjects:         []string{"a"},
+			SANs:             []string{"b"},
+			SPKIPins:         []string{pin},
+			RequireSignature: true,
+		}).String())
+}
@@ -75,6 +75,16 @@ func main() {
 		sharedSecrets[i] = strings.TrimSpace(sharedSecrets[i])
 	}
 
+	// The bearer token is read from the environment for each registration.
+	var auth listener.Option
+	if os.Getenv("WEBHOOK_BEARER_TOKEN") != "" {
+		auth = listener.DecorateRequest(listener.BearerAuth(listener.CredentialFunc(
+			func(context.Context) (string, error) {
+				return os.Getenv("WEBHOOK_BEARER_TOKEN"), nil
+			},
+		)))
+	}
+
 	// Create the listener.
 	whl, err := listener.New(webhookURL,
 		&webhook.Registration{
@@ -85,15 +95,7 @@ func main() {
 			Events:   []string{events},
 			Duration: webhook.CustomDuration(15 * time.Second),
 		},
-		listener.DecorateRequest(listener.DecoratorFunc(
-			func(r *http.Request) error {
-				if os.Getenv("WEBHOOK_BEARER_TOKEN") == "" {
-					return nil
-				}
-				r.Header.Set("Authorization", "Bearer "+os.Getenv("WEBHOOK_BEARER_TOKEN"))
-				return nil
-			},
-		)),
+		auth,
 		listener.AcceptSHA1(),
 		listener.Once(),
 		listener.AcceptedSecrets(sharedSecrets...),
@@ -0,0 +1,528 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/webhook-schema"
+)
+
+// Config is the declarative configuration of a Listener.  It can be
+// unmarshaled from JSON or YAML and turned into a Listener using
+// NewFromConfig().
+type Config struct {
+	// URL is the webhook registration endpoint.  Required.
+	URL string `json:"url" yaml:"url"`
+
+	// AlternateURLs are additional webhook registration endpoints to use when
+	// the URL is unavailable.
+	AlternateURLs []string `json:"alternate_urls" yaml:"alternate_urls"`
+
+	// RoundRobin causes the registrations to be spread across the URL and
+	// AlternateURLs instead of always starting with the URL.
+	RoundRobin bool `json:"round_robin" yaml:"round_robin"`
+
+	// FanOut causes the webhook to be registered with the URL and every one
+	// of the AlternateURLs instead of failing over between them.
+	FanOut bool `json:"fan_out" yaml:"fan_out"`
+
+	// Registration describes the webhook to register.
+	Registration RegistrationConfig `json:"registration" yaml:"registration"`
+
+	// Interval is the interval to wait between webhook registrations.  A
+	// value of 0 causes the webhook to only be registered once.
+	Interval Duration `json:"interval" yaml:"interval"`
+
+	// Hashes is the list of hash algorithms accepted when validating the
+	// callbacks in order of preference.  Valid values are "sha1", "sha256"
+	// and "none".  Required.
+	Hashes []string `json:"hashes" yaml:"hashes"`
+
+	// Secrets is the list of secrets accepted when validating the callbacks.
+	Secrets []SecretConfig `json:"secrets" yaml:"secrets"`
+
+	// Auth describes how to authenticate the registration requests.
+	Auth AuthConfig `json:"auth" yaml:"auth"`
+
+	// HTTPClient describes the http client used for the registration
+	// requests.
+	HTTPClient HTTPClientConfig `json:"http_client" yaml:"http_client"`
+}
+
+// RegistrationConfig is the declarative form of a webhook.Registration.
+type RegistrationConfig struct {
+	// ReceiverURL is the URL the webhook callbacks are delivered to.
+	ReceiverURL string `json:"receiver_url" yaml:"receiver_url"`
+
+	// AlternativeURLs is a list of URLs to deliver callbacks to when the
+	// ReceiverURL fails.
+	AlternativeURLs []string `json:"alt_urls" yaml:"alt_urls"`
+
+	// ContentType is the content type of the webhook callbacks.
+	ContentType string `json:"content_type" yaml:"content_type"`
+
+	// Secret is the secret used to sign the webhook callbacks.  Only one of
+	// Secret or SecretFile may be set.
+	Secret string `json:"secret" yaml:"secret"`
+
+	// SecretFile is the path to a file containing the secret used to sign the
+	// webhook callbacks.  Only one of Secret or SecretFile may be set.
+	SecretFile string `json:"secret_file" yaml:"secret_file"`
+
+	// FailureURL is the URL to notify when the webhook is cut off.
+	FailureURL string `json:"failure_url" yaml:"failure_url"`
+
+	// Events is the list of regular expressions matching the event types.
+	Events []string `json:"events" yaml:"events"`
+
+	// DeviceIDs is the list of regular expressions matching the device ids.
+	DeviceIDs []string `json:"device_ids" yaml:"device_ids"`
+
+	// Duration is how long the registration lasts.  Required.
+	Duration Duration `json:"duration" yaml:"duration"`
+}
+
+// SecretConfig is a secret accepted when validating the callbacks.  Only one
+// of Value or File may be set.
+type SecretConfig struct {
+	// Label identifies the secret in events.
+	Label string `json:"label" yaml:"label"`
+
+	// Value is the secret.
+	Value string `json:"value" yaml:"value"`
+
+	// File is the path to a file containing the secret.
+	File string `json:"file" yaml:"file"`
+}
+
+// AuthConfig describes how to authenticate the registration requests.  Only
+// one of Bearer or Basic may be set.
+type AuthConfig struct {
+	// Bearer configures bearer token authentication.
+	Bearer *BearerAuthConfig `json:"bearer" yaml:"bearer"`
+
+	// Basic configures basic authentication.
+	Basic *BasicAuthConfig `json:"basic" yaml:"basic"`
+}
+
+// BearerAuthConfig configures bearer token authentication.  Only one of Token
+// or TokenFile may be set.
+type BearerAuthConfig struct {
+	// Token is the bearer token.
+	Token string `json:"token" yaml:"token"`
+
+	// TokenFile is the path to a file containing the bearer token.
+	TokenFile string `json:"token_file" yaml:"token_file"`
+}
+
+// BasicAuthConfig configures basic authentication.  Only one of Password or
+// PasswordFile may be set.
+type BasicAuthConfig struct {
+	// Username is the username.
+	Username string `json:"username" yaml:"username"`
+
+	// Password is the password.
+	Password string `json:"password" yaml:"password"`
+
+	// PasswordFile is the path to a file containing the password.
+	PasswordFile string `json:"password_file" yaml:"password_file"`
+}
+
+// HTTPClientConfig describes the http client used for the registration
+// requests.
+type HTTPClientConfig struct {
+	// Timeout is the overall timeout of a registration request.  A value of 0
+	// means no timeout.
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+
+	// TLS configures the TLS settings of the client.
+	TLS *TLSConfig `json:"tls" yaml:"tls"`
+}
+
+// TLSConfig configures the TLS settings of the http client.
+type TLSConfig struct {
+	// CAFile is the path to a PEM file of the certificate authorities to
+	// trust.  If empty the system pool is used.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+
+	// CertFile is the path to the PEM client certificate.  CertFile and
+	// KeyFile must be set together.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+
+	// KeyFile is the path to the PEM client key.  CertFile and KeyFile must be
+	// set together.
+	KeyFile string `json:"key_file" yaml:"key_file"`
+
+	// ServerName overrides the server name used to verify the certificate.
+	ServerName string `json:"server_name" yaml:"server_name"`
+
+	// InsecureSkipVerify disables the verification of the server certificate.
+	//
+	// USE WITH CAUTION.
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+// Duration is a time.Duration that is unmarshaled from a string such as "5m"
+// in both JSON and YAML.
+type Duration time.Duration
+
+// UnmarshalText parses the duration using time.ParseDuration.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration using time.Duration.String.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// ConfigError is returned when a Config is invalid.  Field holds the path of
+// the offending field using the JSON/YAML names, for example "secrets[1].file".
+type ConfigError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return "config " + e.Field + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// configErr creates a ConfigError for the field that wraps ErrInput.
+func configErr(field string, format string, a ...any) error {
+	return &ConfigError{
+		Field: field,
+		Err:   fmt.Errorf("%w: "+format, append([]any{ErrInput}, a...)...),
+	}
+}
+
+// NewFromConfig creates a new webhook listener from the configuration.  Any
+// additional options are applied after the options described by the
+// configuration.  Errors caused by the configuration are a *ConfigError, and
+// errors caused by the additional options are returned as they are.
+func NewFromConfig(c Config, opts ...Option) (*Listener, error) {
+	url := strings.TrimSpace(c.URL)
+	if url == "" {
+		return nil, configErr("url", "webhook url is required")
+	}
+
+	r, err := c.Registration.registration()
+	if err != nil {
+		return nil, err
+	}
+
+	cOpts, err := c.options()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the registration the same way the listener does, so the error
+	// is attributed to the configuration.
+	p := registrationPayload{r: r}
+	if err := p.Validate(time.Duration(c.Interval)); err != nil {
+		return nil, &ConfigError{
+			Field: "registration",
+			Err:   errors.Join(err, ErrInput),
+		}
+	}
+
+	return New(url, r, append(cOpts, opts...)...)
+}
+
+// registration builds and validates the webhook.Registration.
+func (rc RegistrationConfig) registration() (*webhook.Registration, error) {
+	if rc.Duration <= 0 {
+		return nil, configErr("registration.duration", "duration must be greater than 0")
+	}
+
+	for i, e := range rc.Events {
+		if _, err := regexp.Compile(e); err != nil {
+			return nil, configErr(fmt.Sprintf("registration.events[%d]", i), "unable to compile '%s'", e)
+		}
+	}
+
+	for i, e := range rc.DeviceIDs {
+		if _, err := regexp.Compile(e); err != nil {
+			return nil, configErr(fmt.Sprintf("registration.device_ids[%d]", i), "unable to compile '%s'", e)
+		}
+	}
+
+	secret, err := valueOrFile("registration", "secret", rc.Secret, "secret_file", rc.SecretFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook.Registration{
+		Config: webhook.DeliveryConfig{
+			ReceiverURL:     rc.ReceiverURL,
+			ContentType:     rc.ContentType,
+			Secret:          secret,
+			AlternativeURLs: rc.AlternativeURLs,
+		},
+		FailureURL: rc.FailureURL,
+		Events:     rc.Events,
+		Matcher: webhook.MetadataMatcherConfig{
+			DeviceID: rc.DeviceIDs,
+		},
+		Duration: webhook.CustomDuration(rc.Duration),
+	}, nil
+}
+
+// options builds the list of options described by the configuration.
+func (c Config) options() ([]Option, error) {
+	var opts []Option
+
+	if c.Interval < 0 {
+		return nil, configErr("interval", "interval must be greater than or equal to 0")
+	}
+	if c.Interval > 0 {
+		opts = append(opts, Interval(time.Duration(c.Interval)))
+	}
+
+	seen := map[string]bool{strings.TrimSpace(c.URL): true}
+	for i, url := range c.AlternateURLs {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			return nil, configErr(fmt.Sprintf("alternate_urls[%d]", i), "url cannot be empty")
+		}
+		if seen[url] {
+			return nil, configErr(fmt.Sprintf("alternate_urls[%d]", i), "duplicate url '%s'", url)
+		}
+		seen[url] = true
+	}
+	if len(c.AlternateURLs) > 0 {
+		opts = append(opts, AlternateURLs(c.AlternateURLs...))
+	}
+	if c.RoundRobin {
+		opts = append(opts, RoundRobin())
+	}
+	if c.FanOut {
+		opts = append(opts, FanOut())
+	}
+
+	if len(c.Hashes) == 0 {
+		return nil, configErr("hashes", "at least one hash is required")
+	}
+	for i, name := range c.Hashes {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "none":
+			opts = append(opts, AcceptNoHash())
+		case "sha1":
+			opts = append(opts, AcceptSHA1())
+		case "sha256":
+			opts = append(opts, AcceptSHA256())
+		default:
+			return nil, configErr(fmt.Sprintf("hashes[%d]", i), "unknown hash '%s'", name)
+		}
+	}
+
+	secrets := make([]Secret, 0, len(c.Secrets))
+	for i, sc := range c.Secrets {
+		field := fmt.Sprintf("secrets[%d]", i)
+		value, err := valueOrFile(field, "value", sc.Value, "file", sc.File)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, configErr(field, "a value or file is required")
+		}
+		secrets = append(secrets, Secret{
+			Label: sc.Label,
+			Value: value,
+		})
+	}
+	if len(secrets) > 0 {
+		opts = append(opts, AcceptedLabeledSecrets(secrets...))
+	}
+
+	d, err := c.Auth.decorator()
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		opts = append(opts, DecorateRequest(d))
+	}
+
+	client, certs, err := c.HTTPClient.client()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		opts = append(opts, &httpClientOption{
+			client: client,
+			certs:  certs,
+		})
+	}
+
+	return opts, nil
+}
+
+// decorator builds the decorator that authenticates the registration
+// requests.  If no authentication is configured nil is returned.
+func (ac AuthConfig) decorator() (Decorator, error) {
+	if ac.Bearer != nil && ac.Basic != nil {
+		return nil, configErr("auth", "only one of bearer or basic may be set")
+	}
+
+	if ac.Bearer != nil {
+		token, err := credentialOrFile("auth.bearer", "token", ac.Bearer.Token, "token_file", ac.Bearer.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			return nil, configErr("auth.bearer", "a token or token_file is required")
+		}
+		return BearerAuth(token), nil
+	}
+
+	if ac.Basic != nil {
+		if ac.Basic.Username == "" {
+			return nil, configErr("auth.basic.username", "username is required")
+		}
+		password, err := credentialOrFile("auth.basic", "password", ac.Basic.Password, "password_file", ac.Basic.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		return BasicAuth(ac.Basic.Username, password), nil
+	}
+
+	return nil, nil
+}
+
+// client builds the http client and the reloader of its client certificate,
+// if it has one.  If the default client is sufficient nil is returned.
+func (hc HTTPClientConfig) client() (*http.Client, *certReloader, error) {
+	if hc.Timeout < 0 {
+		return nil, nil, configErr("http_client.timeout", "timeout must be greater than or equal to 0")
+	}
+
+	if hc.Timeout == 0 && hc.TLS == nil {
+		return nil, nil, nil
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(hc.Timeout),
+	}
+
+	var certs *certReloader
+	if hc.TLS != nil {
+		var tlsConfig *tls.Config
+		var err error
+		tlsConfig, certs, err = hc.TLS.config()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+
+	return &client, certs, nil
+}
+
+// config builds the tls.Config and the reloader of the client certificate, if
+// there is one.
+func (tc TLSConfig) config() (*tls.Config, *certReloader, error) {
+	c := tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if tc.CAFile != "" {
+		pem, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, nil, &ConfigError{
+				Field: "http_client.tls.ca_file",
+				Err:   errors.Join(err, ErrInput),
+			}
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, configErr("http_client.tls.ca_file", "no certificates found")
+		}
+	}
+
+	if (tc.CertFile == "") != (tc.KeyFile == "") {
+		return nil, nil, configErr("http_client.tls", "cert_file and key_file must be set together")
+	}
+
+	var cr *certReloader
+	if tc.CertFile != "" {
+		// The certificate is loaded again when the files change.  Failures
+		// are dispatched as events once the listener is created.
+		var err error
+		cr, err = newCertReloader(tc.CertFile, tc.KeyFile, nil)
+		if err != nil {
+			return nil, nil, &ConfigError{
+				Field: "http_client.tls.cert_file",
+				Err:   errors.Join(err, ErrInput),
+			}
+		}
+		c.GetClientCertificate = cr.GetClientCertificate
+	}
+
+	return &c, cr, nil
+}
+
+// credentialOrFile returns a Credential for the value or the file.  Only one
+// of them may be set.  The file is read now to validate it, and the returned
+// Credential reads it again whenever it changes.  If neither is set, nil is
+// returned.
+func credentialOrFile(field, valueName, value, fileName, file string) (Credential, error) {
+	v, err := valueOrFile(field, valueName, value, fileName, file)
+	if err != nil || v == "" {
+		return nil, err
+	}
+
+	if file != "" {
+		return FileCredential(file), nil
+	}
+	return StaticCredential(value), nil
+}
+
+// valueOrFile returns the value or the trimmed contents of the file.  Only
+// one of them may be set.
+func valueOrFile(field, valueName, value, fileName, file string) (string, error) {
+	if value != "" && file != "" {
+		return "", configErr(field, "only one of %s or %s may be set", valueName, fileName)
+	}
+
+	if file == "" {
+		return value, nil
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", &ConfigError{
+			Field: field + "." + fileName,
+			Err:   errors.Join(err, ErrInput),
+		}
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
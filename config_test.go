@@ -0,0 +1,391 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+	"go.yaml.in/yaml/v3"
+)
+
+const configJSON = `{
+	"url": "http://example.com/hook",
+	"registration": {
+		"receiver_url": "http://example.com/events",
+		"content_type": "application/json",
+		"secret": "foobar",
+		"events": ["device-status.*"],
+		"device_ids": ["mac:.*"],
+		"duration": "5m"
+	},
+	"interval": "1m",
+	"hashes": ["sha256", "sha1"],
+	"secrets": [
+		{"label": "new", "value": "foobar"},
+		{"label": "old", "value": "carport"}
+	],
+	"auth": {
+		"basic": {"username": "user", "password": "pass"}
+	},
+	"http_client": {
+		"timeout": "10s"
+	}
+}`
+
+const configYAML = `
+url: http://example.com/hook
+registration:
+  receiver_url: http://example.com/events
+  content_type: application/json
+  secret: foobar
+  events:
+    - device-status.*
+  device_ids:
+    - mac:.*
+  duration: 5m
+interval: 1m
+hashes:
+  - sha256
+  - sha1
+secrets:
+  - label: new
+    value: foobar
+  - label: old
+    value: carport
+auth:
+  basic:
+    username: user
+    password: pass
+http_client:
+  timeout: 10s
+`
+
+func TestConfig_Unmarshal(t *testing.T) {
+	var fromJSON, fromYAML Config
+
+	require.NoError(t, json.Unmarshal([]byte(configJSON), &fromJSON))
+	require.NoError(t, yaml.Unmarshal([]byte(configYAML), &fromYAML))
+	assert.Equal(t, fromJSON, fromYAML)
+
+	for _, c := range []Config{fromJSON, fromYAML} {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		l, err := NewFromConfig(c)
+		require.NoError(err)
+		require.NotNil(l)
+
+		assert.Equal("http://example.com/hook", l.webhookURL)
+		assert.Equal(time.Minute, l.interval)
+		assert.Equal([]string{"sha256", "sha1"}, l.hashPreferences)
+		assert.Equal([]string{"foobar", "carport"}, l.acceptedSecrets)
+		assert.Equal([]string{"new", "old"}, l.secretLabels)
+		assert.Equal("foobar", registrationOf(l).Config.Secret)
+		assert.Equal(5*time.Minute, time.Duration(registrationOf(l).Duration))
+		assert.Equal([]string{"mac:.*"}, registrationOf(l).Matcher.DeviceID)
+		assert.Equal(10*time.Second, l.client.Timeout)
+
+		require.Len(l.reqDecorators, 1)
+		assert.NotContains(l.String(), "pass")
+
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+		require.NoError(l.reqDecorators[0].Decorate(req))
+		user, pass, ok := req.BasicAuth()
+		assert.True(ok)
+		assert.Equal("user", user)
+		assert.Equal("pass", pass)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("  from-file\n"), 0600))
+	missingFile := filepath.Join(dir, "missing")
+
+	valid := func() Config {
+		return Config{
+			URL: "http://example.com",
+			Registration: RegistrationConfig{
+				Duration: Duration(5 * time.Minute),
+			},
+			Hashes: []string{"sha256"},
+		}
+	}
+
+	tests := []struct {
+		description string
+		modify      func(*Config)
+		check       func(*assert.Assertions, *Listener)
+		field       string
+	}{
+		{
+			description: "minimal config",
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal(http.DefaultClient, l.client)
+				assert.Equal(time.Duration(0), l.interval)
+				assert.Empty(l.reqDecorators)
+			},
+		}, {
+			description: "secrets read from files",
+			modify: func(c *Config) {
+				c.Registration.SecretFile = secretFile
+				c.Secrets = []SecretConfig{{File: secretFile}}
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal("from-file", registrationOf(l).Config.Secret)
+				assert.Equal([]string{"from-file"}, l.acceptedSecrets)
+			},
+		}, {
+			description: "bearer auth from a file",
+			modify: func(c *Config) {
+				c.Auth.Bearer = &BearerAuthConfig{TokenFile: secretFile}
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+				assert.NoError(l.reqDecorators[0].Decorate(req))
+				assert.Equal("Bearer from-file", req.Header.Get("Authorization"))
+				assert.Equal("DecorateRequest(BearerAuth(***))", DecorateRequest(l.reqDecorators[0]).String())
+			},
+		}, {
+			description: "insecure tls",
+			modify: func(c *Config) {
+				c.HTTPClient.TLS = &TLSConfig{InsecureSkipVerify: true}
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				transport, ok := l.client.Transport.(*http.Transport)
+				if assert.True(ok) {
+					assert.True(transport.TLSClientConfig.InsecureSkipVerify)
+				}
+			},
+		}, {
+			description: "alternate urls with round robin and fan out",
+			modify: func(c *Config) {
+				c.AlternateURLs = []string{"http://b.example.com"}
+				c.RoundRobin = true
+				c.FanOut = true
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal([]string{"http://example.com", "http://b.example.com"}, l.endpoints.urls())
+				assert.True(l.endpoints.roundRobin)
+				assert.True(l.endpoints.fanOut)
+			},
+		}, {
+			description: "empty alternate url",
+			modify:      func(c *Config) { c.AlternateURLs = []string{"http://b.example.com", ""} },
+			field:       "alternate_urls[1]",
+		}, {
+			description: "duplicate alternate url",
+			modify:      func(c *Config) { c.AlternateURLs = []string{"http://b.example.com", "http://b.example.com"} },
+			field:       "alternate_urls[1]",
+		}, {
+			description: "alternate url duplicates the url",
+			modify:      func(c *Config) { c.AlternateURLs = []string{" http://example.com"} },
+			field:       "alternate_urls[0]",
+		}, {
+			description: "missing url",
+			modify:      func(c *Config) { c.URL = " " },
+			field:       "url",
+		}, {
+			description: "missing duration",
+			modify:      func(c *Config) { c.Registration.Duration = 0 },
+			field:       "registration.duration",
+		}, {
+			description: "invalid event regex",
+			modify:      func(c *Config) { c.Registration.Events = []string{"ok", "bad("} },
+			field:       "registration.events[1]",
+		}, {
+			description: "invalid device id regex",
+			modify:      func(c *Config) { c.Registration.DeviceIDs = []string{"bad("} },
+			field:       "registration.device_ids[0]",
+		}, {
+			description: "both secret and secret file",
+			modify: func(c *Config) {
+				c.Registration.Secret = "foo"
+				c.Registration.SecretFile = secretFile
+			},
+			field: "registration",
+		}, {
+			description: "missing secret file",
+			modify:      func(c *Config) { c.Registration.SecretFile = missingFile },
+			field:       "registration.secret_file",
+		}, {
+			description: "negative interval",
+			modify:      func(c *Config) { c.Interval = -1 },
+			field:       "interval",
+		}, {
+			description: "unknown hash",
+			modify:      func(c *Config) { c.Hashes = []string{"sha1", "md5"} },
+			field:       "hashes[1]",
+		}, {
+			description: "no hashes",
+			modify:      func(c *Config) { c.Hashes = nil },
+			field:       "hashes",
+		}, {
+			description: "empty secret",
+			modify:      func(c *Config) { c.Secrets = []SecretConfig{{Value: "a"}, {Label: "b"}} },
+			field:       "secrets[1]",
+		}, {
+			description: "missing accepted secret file",
+			modify:      func(c *Config) { c.Secrets = []SecretConfig{{File: missingFile}} },
+			field:       "secrets[0].file",
+		}, {
+			description: "both bearer and basic",
+			modify: func(c *Config) {
+				c.Auth.Bearer = &BearerAuthConfig{Token: "a"}
+				c.Auth.Basic = &BasicAuthConfig{Username: "b"}
+			},
+			field: "auth",
+		}, {
+			description: "empty bearer",
+			modify:      func(c *Config) { c.Auth.Bearer = &BearerAuthConfig{} },
+			field:       "auth.bearer",
+		}, {
+			description: "basic without a username",
+			modify:      func(c *Config) { c.Auth.Basic = &BasicAuthConfig{Password: "b"} },
+			field:       "auth.basic.username",
+		}, {
+			description: "negative timeout",
+			modify:      func(c *Config) { c.HTTPClient.Timeout = -1 },
+			field:       "http_client.timeout",
+		}, {
+			description: "missing ca file",
+			modify:      func(c *Config) { c.HTTPClient.TLS = &TLSConfig{CAFile: missingFile} },
+			field:       "http_client.tls.ca_file",
+		}, {
+			description: "ca file without certificates",
+			modify:      func(c *Config) { c.HTTPClient.TLS = &TLSConfig{CAFile: secretFile} },
+			field:       "http_client.tls.ca_file",
+		}, {
+			description: "cert without key",
+			modify:      func(c *Config) { c.HTTPClient.TLS = &TLSConfig{CertFile: secretFile} },
+			field:       "http_client.tls",
+		}, {
+			description: "invalid cert",
+			modify: func(c *Config) {
+				c.HTTPClient.TLS = &TLSConfig{CertFile: secretFile, KeyFile: secretFile}
+			},
+			field: "http_client.tls.cert_file",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			c := valid()
+			if tc.modify != nil {
+				tc.modify(&c)
+			}
+
+			l, err := NewFromConfig(c)
+
+			if tc.field != "" {
+				assert.Nil(l)
+				assert.ErrorIs(err, ErrInput)
+
+				var ce *ConfigError
+				require.True(errors.As(err, &ce))
+				assert.Equal(tc.field, ce.Field)
+				assert.Contains(err.Error(), tc.field)
+				return
+			}
+
+			require.NoError(err)
+			require.NotNil(l)
+			if tc.check != nil {
+				tc.check(assert, l)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig_OptionErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// Errors caused by the additional options are not configuration errors.
+	l, err := NewFromConfig(Config{
+		URL: "http://example.com",
+		Registration: RegistrationConfig{
+			Duration: Duration(5 * time.Minute),
+		},
+		Hashes: []string{"sha256"},
+	}, Interval(-1))
+	assert.Nil(l)
+	assert.ErrorIs(err, ErrInput)
+
+	var ce *ConfigError
+	assert.False(errors.As(err, &ce))
+}
+
+func TestNewFromConfig_CertificateReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	mod := time.Now()
+	cert, key := ca.issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "client"}})
+	writeFile(t, certFile, cert, mod)
+	writeFile(t, keyFile, key, mod)
+
+	var events []event.Registration
+	l, err := NewFromConfig(Config{
+		URL: "http://example.com",
+		Registration: RegistrationConfig{
+			Duration: Duration(5 * time.Minute),
+		},
+		Hashes: []string{"sha256"},
+		HTTPClient: HTTPClientConfig{
+			TLS: &TLSConfig{CertFile: certFile, KeyFile: keyFile},
+		},
+	},
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NoError(err)
+
+	transport, ok := l.client.Transport.(*http.Transport)
+	require.True(ok)
+
+	// A certificate that fails to load is reported and the previous
+	// certificate is used.
+	writeFile(t, keyFile, []byte("invalid"), mod.Add(time.Minute))
+
+	got, err := transport.TLSClientConfig.GetClientCertificate(nil)
+	require.NoError(err)
+	assert.NotNil(got)
+	require.Len(events, 1)
+	assert.ErrorIs(events[0].Err, ErrCertificateReloadFailed)
+}
+
+func TestDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	var d Duration
+	assert.Error(d.UnmarshalText([]byte("invalid")))
+	assert.NoError(d.UnmarshalText([]byte("90s")))
+	assert.Equal("1m30s", d.String())
+
+	b, err := d.MarshalText()
+	assert.NoError(err)
+	assert.Equal("1m30s", string(b))
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy determines what happens to an asynchronously dispatched event
+// when the event buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until there is room in the buffer for the event.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the event being dispatched.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest buffered event to make room for
+	// the event being dispatched.
+	OverflowDropOldest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowDropOldest:
+		return "DropOldest"
+	}
+	return "Unknown"
+}
+
+// dispatcher delivers events to the event listeners from a background
+// goroutine so slow event listeners do not delay the caller.
+type dispatcher struct {
+	m       sync.RWMutex
+	closed  bool
+	policy  OverflowPolicy
+	queue   chan func()
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// newDispatcher creates a dispatcher with the given buffer size and overflow
+// policy and starts the background goroutine.
+func newDispatcher(size int, policy OverflowPolicy) *dispatcher {
+	d := dispatcher{
+		policy: policy,
+		queue:  make(chan func(), size),
+		done:   make(chan struct{}),
+	}
+
+	go d.run()
+
+	return &d
+}
+
+func (d *dispatcher) run() {
+	defer close(d.done)
+
+	for fn := range d.queue {
+		fn()
+	}
+}
+
+// send queues the function to be called by the background goroutine.  If the
+// dispatcher is nil or has been stopped, the function is called immediately.
+func (d *dispatcher) send(fn func()) {
+	if d == nil {
+		fn()
+		return
+	}
+
+	d.m.RLock()
+	if d.closed {
+		d.m.RUnlock()
+		fn()
+		return
+	}
+	defer d.m.RUnlock()
+
+	switch d.policy {
+	case OverflowDropNewest:
+		select {
+		case d.queue <- fn:
+		default:
+			d.dropped.Add(1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case d.queue <- fn:
+				return
+			default:
+			}
+
+			select {
+			case <-d.queue:
+				d.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		d.queue <- fn
+	}
+}
+
+// stop stops accepting new events and waits for the buffered events to be
+// delivered.  It is safe to call stop more than once.
+func (d *dispatcher) stop() {
+	if d == nil {
+		return
+	}
+
+	d.m.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.m.Unlock()
+
+	<-d.done
+}
+
+// droppedCount returns the number of events that have been discarded.
+func (d *dispatcher) droppedCount() uint64 {
+	if d == nil {
+		return 0
+	}
+	return d.dropped.Load()
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+func TestOverflowPolicy_String(t *testing.T) {
+	assert.Equal(t, "Block", OverflowBlock.String())
+	assert.Equal(t, "DropNewest", OverflowDropNewest.String())
+	assert.Equal(t, "DropOldest", OverflowDropOldest.String())
+	assert.Equal(t, "Unknown", OverflowPolicy(99).String())
+}
+
+func TestDispatcher(t *testing.T) {
+	tests := []struct {
+		description string
+		policy      OverflowPolicy
+		expected    []int
+		dropped     uint64
+	}{
+		{
+			description: "block delivers everything",
+			policy:      OverflowBlock,
+			expected:    []int{0, 1, 2, 3, 4},
+		}, {
+			description: "drop newest keeps the first events",
+			policy:      OverflowDropNewest,
+			expected:    []int{0, 1, 2},
+			dropped:     2,
+		}, {
+			description: "drop oldest keeps the last events",
+			policy:      OverflowDropOldest,
+			expected:    []int{0, 3, 4},
+			dropped:     2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			d := newDispatcher(2, tc.policy)
+
+			var m sync.Mutex
+			var got []int
+			started := make(chan struct{})
+			release := make(chan struct{})
+
+			// The first event blocks the background goroutine so the buffer
+			// fills up.
+			d.send(func() {
+				close(started)
+				<-release
+				m.Lock()
+				got = append(got, 0)
+				m.Unlock()
+			})
+			<-started
+
+			sent := make(chan struct{})
+			go func() {
+				for i := 1; i < 5; i++ {
+					d.send(func() {
+						m.Lock()
+						got = append(got, i)
+						m.Unlock()
+					})
+				}
+				close(sent)
+			}()
+
+			if tc.policy == OverflowBlock {
+				// The sends can only complete once the buffer drains.
+				close(release)
+				<-sent
+			} else {
+				// Wait for all the sends to complete before releasing.
+				<-sent
+				close(release)
+			}
+
+			d.stop()
+
+			assert.Equal(tc.expected, got)
+			assert.Equal(tc.dropped, d.droppedCount())
+
+			// After stopping, events are delivered synchronously.
+			called := false
+			d.send(func() { called = true })
+			assert.True(called)
+
+			// Stopping again is a no-op.
+			d.stop()
+		})
+	}
+}
+
+func TestDispatcher_Nil(t *testing.T) {
+	var d *dispatcher
+
+	called := false
+	d.send(func() { called = true })
+	assert.True(t, called)
+	assert.Equal(t, uint64(0), d.droppedCount())
+	d.stop()
+}
+
+func TestListener_AsyncEvents(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var m sync.Mutex
+	var got []event.Authorize
+
+	r := validWHR
+	l, err := New("http://example.com", &r,
+		AsyncEvents(10, OverflowBlock),
+		WithAuthorizeEventListener(event.AuthorizeFunc(
+			func(e event.Authorize) {
+				m.Lock()
+				defer m.Unlock()
+				got = append(got, e)
+			})),
+	)
+	require.NoError(err)
+	require.NotNil(l)
+
+	err = l.Authorize(nil, nil)
+	assert.ErrorIs(err, ErrNoToken)
+
+	l.Stop()
+
+	m.Lock()
+	defer m.Unlock()
+	require.Len(got, 1)
+	assert.ErrorIs(got[0].Err, ErrNoToken)
+	assert.Equal(uint64(0), l.DroppedEvents())
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxDecodedBody is the largest decoded body allowed if no limit is
+// configured.
+const defaultMaxDecodedBody = 10 << 20
+
+// SignedContent describes which form of a compressed callback body the
+// signature covers.
+type SignedContent int
+
+const (
+	// SignedEncoded means the signature covers the body exactly as it was
+	// sent, before it is decompressed.  The body is only decompressed after
+	// the signature is verified.
+	SignedEncoded SignedContent = iota
+
+	// SignedDecoded means the signature covers the decompressed body.
+	SignedDecoded
+)
+
+func (s SignedContent) String() string {
+	switch s {
+	case SignedEncoded:
+		return "SignedEncoded"
+	case SignedDecoded:
+		return "SignedDecoded"
+	}
+	return "SignedContent(" + strconv.Itoa(int(s)) + ")"
+}
+
+// AcceptContentEncoding is an option that enables webhook callbacks with gzip
+// or deflate compressed bodies.  The signed value determines if the signature
+// is verified against the compressed or the decompressed body.  After a
+// successful Authorize() the request body is replaced by the decompressed body
+// and the Content-Encoding header is removed, so downstream handlers always
+// see the decompressed body.
+//
+// The maxSize is the largest decompressed body allowed, which prevents
+// compression bombs.  If 0, 10MB is used.  Larger bodies are rejected with
+// ErrBodyTooLarge.  Bodies with other content encodings are rejected with
+// ErrUnsupportedContentEncoding.
+//
+// Without this option the body is never decompressed and the signature always
+// covers the body as sent.
+func AcceptContentEncoding(signed SignedContent, maxSize int64) Option {
+	return &acceptContentEncodingOption{
+		signed:  signed,
+		maxSize: maxSize,
+	}
+}
+
+type acceptContentEncodingOption struct {
+	signed  SignedContent
+	maxSize int64
+}
+
+func (a acceptContentEncodingOption) apply(lis *Listener) error {
+	if a.signed != SignedEncoded && a.signed != SignedDecoded {
+		return fmt.Errorf("%w: invalid signed content %s", ErrInput, a.signed)
+	}
+	if a.maxSize < 0 {
+		return fmt.Errorf("%w: max size must be greater than or equal to 0", ErrInput)
+	}
+
+	maxSize := a.maxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxDecodedBody
+	}
+
+	lis.encoding = &contentEncoding{
+		signed:  a.signed,
+		maxSize: maxSize,
+	}
+	return nil
+}
+
+func (a acceptContentEncodingOption) String() string {
+	return "AcceptContentEncoding(" + a.signed.String() + ", " +
+		strconv.FormatInt(a.maxSize, 10) + ")"
+}
+
+// contentEncoding is the configuration for compressed callback bodies.
+type contentEncoding struct {
+	signed  SignedContent
+	maxSize int64
+}
+
+// encodingOf returns the normalized content encoding of the request.  An
+// empty string means the body is not encoded.
+func encodingOf(r *http.Request) string {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch enc {
+	case "identity":
+		return ""
+	case "x-gzip":
+		return "gzip"
+	}
+	return enc
+}
+
+// checkEncoding returns an error if the content encoding cannot be decoded.
+func checkEncoding(enc string) error {
+	switch enc {
+	case "", "gzip", "deflate":
+		return nil
+	}
+	return fmt.Errorf("%w: '%s'", ErrUnsupportedContentEncoding, enc)
+}
+
+// decode decompresses the body using the content encoding, limiting the size
+// of the decompressed body.
+func (c *contentEncoding) decode(enc string, body []byte) ([]byte, error) {
+	if err := checkEncoding(enc); err != nil {
+		return nil, err
+	}
+
+	var rc io.ReadCloser
+	var err error
+
+	switch enc {
+	case "":
+		return body, nil
+	case "gzip":
+		rc, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// Deflate should be zlib wrapped, but some senders use raw deflate.
+		rc, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			rc, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnableToReadBody, err)
+	}
+	defer rc.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(rc, c.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnableToReadBody, err)
+	}
+	if int64(len(decoded)) > c.maxSize {
+		return nil, fmt.Errorf("%w: decoded body exceeds %d bytes", ErrBodyTooLarge, c.maxSize)
+	}
+
+	return decoded, nil
+}
+
+// replaceBody replaces the request body with the decoded body and removes the
+// headers describing the encoded body.
+func replaceBody(r *http.Request, decoded []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(decoded))
+	r.ContentLength = int64(len(decoded))
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+}
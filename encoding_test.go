@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, enc string, body []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw deflate":
+		var err error
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+	default:
+		return body
+	}
+	_, err := w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func signSHA256(body []byte) string {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestAcceptContentEncoding(t *testing.T) {
+	body := []byte(strings.Repeat("hello world ", 100))
+
+	tests := []struct {
+		description string
+		opt         Option
+		header      string
+		compression string
+		signDecoded bool
+		expected    []byte
+		expectedErr error
+	}{
+		{
+			description: "gzip signed encoded",
+			opt:         AcceptContentEncoding(SignedEncoded, 0),
+			header:      "gzip",
+			compression: "gzip",
+		}, {
+			description: "gzip signed decoded",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "gzip",
+			compression: "gzip",
+			signDecoded: true,
+		}, {
+			description: "x-gzip",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "X-Gzip",
+			compression: "gzip",
+			signDecoded: true,
+		}, {
+			description: "deflate",
+			opt:         AcceptContentEncoding(SignedEncoded, 0),
+			header:      "deflate",
+			compression: "deflate",
+		}, {
+			description: "raw deflate",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "deflate",
+			compression: "raw deflate",
+			signDecoded: true,
+		}, {
+			description: "identity",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "identity",
+			signDecoded: true,
+		}, {
+			description: "not encoded",
+			opt:         AcceptContentEncoding(SignedEncoded, 0),
+		}, {
+			description: "signed decoded, but expected encoded",
+			opt:         AcceptContentEncoding(SignedEncoded, 0),
+			header:      "gzip",
+			compression: "gzip",
+			signDecoded: true,
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "signed encoded, but expected decoded",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "gzip",
+			compression: "gzip",
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "too large signed decoded",
+			opt:         AcceptContentEncoding(SignedDecoded, 100),
+			header:      "gzip",
+			compression: "gzip",
+			signDecoded: true,
+			expectedErr: ErrBodyTooLarge,
+		}, {
+			description: "too large signed encoded",
+			opt:         AcceptContentEncoding(SignedEncoded, 100),
+			header:      "gzip",
+			compression: "gzip",
+			expectedErr: ErrBodyTooLarge,
+		}, {
+			description: "exactly the limit",
+			opt:         AcceptContentEncoding(SignedEncoded, int64(len(body))),
+			header:      "gzip",
+			compression: "gzip",
+		}, {
+			description: "unsupported encoding",
+			opt:         AcceptContentEncoding(SignedEncoded, 0),
+			header:      "br",
+			expectedErr: ErrUnsupportedContentEncoding,
+		}, {
+			description: "corrupt body",
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			header:      "gzip",
+			signDecoded: true,
+			expectedErr: ErrUnableToReadBody,
+		}, {
+			description: "not enabled",
+			header:      "gzip",
+			compression: "gzip",
+			expected:    compress(t, "gzip", body),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			l, err := New("http://example.com", &validWHR,
+				AcceptSHA256(),
+				AcceptedSecrets("secret"),
+				tc.opt,
+			)
+			require.NoError(err)
+
+			sent := compress(t, tc.compression, body)
+			signature := signSHA256(sent)
+			if tc.signDecoded {
+				signature = signSHA256(body)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(sent))
+			r.Header.Set(xmidtHeader, signature)
+			if tc.header != "" {
+				r.Header.Set("Content-Encoding", tc.header)
+			}
+
+			token, err := l.Tokenize(r)
+			require.NoError(err)
+
+			err = l.Authorize(r, token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				return
+			}
+			require.NoError(err)
+
+			expected := body
+			if tc.expected != nil {
+				expected = tc.expected
+			}
+
+			got, err := io.ReadAll(r.Body)
+			require.NoError(err)
+			assert.Equal(expected, got)
+			assert.Equal(int64(len(expected)), r.ContentLength)
+			if tc.opt != nil && tc.compression != "" {
+				assert.Empty(r.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestAcceptContentEncodingOption(t *testing.T) {
+	tests := []newTest{
+		{
+			description: "assert the default limit works",
+			r:           validWHR,
+			opt:         AcceptContentEncoding(SignedDecoded, 0),
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal(SignedDecoded, l.encoding.signed)
+				assert.Equal(int64(defaultMaxDecodedBody), l.encoding.maxSize)
+			},
+		}, {
+			description: "assert a limit works",
+			r:           validWHR,
+			opt:         AcceptContentEncoding(SignedEncoded, 1024),
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal(SignedEncoded, l.encoding.signed)
+				assert.Equal(int64(1024), l.encoding.maxSize)
+			},
+		}, {
+			description: "assert a negative limit errors",
+			r:           validWHR,
+			opt:         AcceptContentEncoding(SignedEncoded, -1),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid signed content errors",
+			r:           validWHR,
+			opt:         AcceptContentEncoding(SignedContent(5), 0),
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+
+	assert.Equal(t, "AcceptContentEncoding(SignedDecoded, 1024)",
+		AcceptContentEncoding(SignedDecoded, 1024).String())
+	assert.Equal(t, "AcceptContentEncoding(SignedContent(5), 0)",
+		AcceptContentEncoding(SignedContent(5), 0).String())
+}
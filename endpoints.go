@@ -0,0 +1,338 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// endpointCooldown is how long an endpoint that failed once is tried only
+	// after the healthy endpoints.  The cooldown doubles with each consecutive
+	// failure up to maxEndpointCooldown.
+	endpointCooldown    = 30 * time.Second
+	maxEndpointCooldown = 8 * time.Minute
+
+	// minRenewal is the shortest wait before renewing a registration unless
+	// the interval is shorter, so a webhook server that reports registrations
+	// that are about to expire cannot cause a busy loop.
+	minRenewal = 100 * time.Millisecond
+)
+
+// renewAt returns when a registration accepted at the given time that expires
+// at until needs renewing.  This is after the interval, or once nine tenths of
+// the lifetime of the registration has passed if that is sooner.
+func renewAt(at, until time.Time, interval time.Duration) time.Time {
+	next := at.Add(interval)
+	if until.IsZero() {
+		return next
+	}
+	if early := until.Add(-until.Sub(at) / 10); early.Before(next) {
+		return early
+	}
+	return next
+}
+
+// endpoint tracks the health and registration of a webhook registration
+// endpoint.
+type endpoint struct {
+	url      string
+	failures int
+	retryAt  time.Time
+
+	// until is when the registration held by the endpoint expires.
+	until time.Time
+
+	// renewed is when the endpoint last accepted the registration and pending
+	// is true if the endpoint needs to be registered again without waiting
+	// for the renewal.  Both are only used when fan out is used.
+	renewed time.Time
+	pending bool
+
+	// err is the permanent failure that stopped the registrations with the
+	// endpoint when fan out is used.
+	err error
+}
+
+// endpoints is the set of webhook registration endpoints and the strategy used
+// to choose between them.
+type endpoints struct {
+	m          sync.Mutex
+	list       []endpoint
+	roundRobin bool
+	fanOut     bool
+	next       int
+	now        func() time.Time
+}
+
+// newEndpoints creates the set of endpoints from the urls in order of
+// preference.
+func newEndpoints(urls ...string) *endpoints {
+	e := endpoints{
+		list: make([]endpoint, 0, len(urls)),
+		now:  time.Now,
+	}
+	for _, url := range urls {
+		e.list = append(e.list, endpoint{url: url, pending: true})
+	}
+	return &e
+}
+
+// add appends the urls to the set of endpoints.  An error is returned if a
+// url is already one of the endpoints.
+func (e *endpoints) add(urls ...string) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for _, url := range urls {
+		for _, ep := range e.list {
+			if ep.url == url {
+				return fmt.Errorf("%w, duplicate endpoint '%s'", ErrInput, url)
+			}
+		}
+		e.list = append(e.list, endpoint{url: url, pending: true})
+	}
+	return nil
+}
+
+// urls returns all the endpoint urls in order of preference.
+func (e *endpoints) urls() []string {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	urls := make([]string, 0, len(e.list))
+	for _, ep := range e.list {
+		urls = append(urls, ep.url)
+	}
+	return urls
+}
+
+// order returns the endpoint urls in the order they should be tried.  Healthy
+// endpoints are tried before endpoints that failed recently.  When round robin
+// is used, each call starts with the next endpoint.
+func (e *endpoints) order() []string {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	start := 0
+	if e.roundRobin && len(e.list) > 0 {
+		start = e.next
+		e.next = (e.next + 1) % len(e.list)
+	}
+
+	now := e.now()
+	healthy := make([]string, 0, len(e.list))
+	var unhealthy []string
+	for i := range e.list {
+		ep := e.list[(start+i)%len(e.list)]
+		if ep.retryAt.After(now) {
+			unhealthy = append(unhealthy, ep.url)
+			continue
+		}
+		healthy = append(healthy, ep.url)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// report records the outcome of using the endpoint.  The healthy argument
+// indicates the endpoint is available and the until argument is when the
+// accepted registration expires.  A zero until means the registration was not
+// accepted, in which case any previous registration is held until it expires.
+func (e *endpoints) report(url string, healthy bool, until time.Time) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for i := range e.list {
+		if e.list[i].url != url {
+			continue
+		}
+		if !until.IsZero() {
+			e.list[i].until = until
+		}
+		if healthy {
+			e.list[i].failures = 0
+			e.list[i].retryAt = time.Time{}
+			return
+		}
+		e.list[i].failures++
+		cooldown := endpointCooldown
+		for n := 1; n < e.list[i].failures && cooldown < maxEndpointCooldown; n++ {
+			cooldown *= 2
+		}
+		e.list[i].retryAt = e.now().Add(min(cooldown, maxEndpointCooldown))
+		return
+	}
+}
+
+// backoff keeps the endpoint from being preferred for at least the duration
+// requested by the endpoint.
+func (e *endpoints) backoff(url string, d time.Duration) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for i := range e.list {
+		if e.list[i].url != url {
+			continue
+		}
+		if retryAt := e.now().Add(d); retryAt.After(e.list[i].retryAt) {
+			e.list[i].retryAt = retryAt
+		}
+		return
+	}
+}
+
+// due returns the urls of the endpoints that need to be registered when fan
+// out is used.  These are the endpoints that are pending and the endpoints
+// whose registration needs renewing.  See renewAt().  Endpoints that
+// stopped because of a permanent failure are never due.
+func (e *endpoints) due(interval time.Duration) []string {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	now := e.now()
+	urls := make([]string, 0, len(e.list))
+	for _, ep := range e.list {
+		if ep.err != nil {
+			continue
+		}
+		if ep.pending || !renewAt(ep.renewed, ep.until, interval).After(now) {
+			urls = append(urls, ep.url)
+		}
+	}
+	return urls
+}
+
+// attempted records the outcome of a fan out registration with the endpoint.
+// An endpoint that failed stays pending so it is retried without registering
+// the other endpoints again, unless the failure is permanent, in which case
+// the endpoint is stopped.
+func (e *endpoints) attempted(url string, err error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for i := range e.list {
+		if e.list[i].url != url {
+			continue
+		}
+		e.list[i].pending = err != nil
+		if err == nil {
+			e.list[i].renewed = e.now()
+		}
+		if permanent(err) {
+			e.list[i].err = err
+		}
+		return
+	}
+}
+
+// renewIn returns how long until the next endpoint needs to be registered
+// again, which is at most the interval.  See renewAt().
+func (e *endpoints) renewIn(interval time.Duration) time.Duration {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	now := e.now()
+	wait := interval
+	for _, ep := range e.list {
+		if ep.renewed.IsZero() || ep.err != nil {
+			continue
+		}
+		wait = min(wait, renewAt(ep.renewed, ep.until, interval).Sub(now))
+	}
+	return max(wait, min(interval, minRenewal))
+}
+
+// restart marks every endpoint as pending so they are all registered by the
+// next fan out registration, including the endpoints that were stopped.
+func (e *endpoints) restart() {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for i := range e.list {
+		e.list[i].pending = true
+		e.list[i].err = nil
+	}
+}
+
+// stopped returns true if any endpoint was stopped because of a permanent
+// failure.
+func (e *endpoints) stopped() bool {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for _, ep := range e.list {
+		if ep.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// failure returns the permanent failures of the endpoints if every endpoint
+// was stopped, or nil if any endpoint is still registering.
+func (e *endpoints) failure() error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	errs := make([]error, 0, len(e.list))
+	for _, ep := range e.list {
+		if ep.err == nil {
+			return nil
+		}
+		errs = append(errs, ep.err)
+	}
+	return errors.Join(errs...)
+}
+
+// expiration returns the earliest expiration of the registrations held by the
+// endpoints that were not stopped, or the zero time if any of them does not
+// hold one.
+func (e *endpoints) expiration() time.Time {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	var until time.Time
+	for _, ep := range e.list {
+		if ep.err != nil {
+			continue
+		}
+		if ep.until.IsZero() {
+			return time.Time{}
+		}
+		if until.IsZero() || ep.until.Before(until) {
+			until = ep.until
+		}
+	}
+	return until
+}
+
+// status returns the aggregate registration status and whether each endpoint
+// holds a registration that has not expired.  Unless fan out is used, the
+// registration only needs to be held by one endpoint.
+func (e *endpoints) status() (RegistrationStatus, map[string]bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	now := e.now()
+	var count int
+	registered := make(map[string]bool, len(e.list))
+	for _, ep := range e.list {
+		registered[ep.url] = ep.until.After(now)
+		if registered[ep.url] {
+			count++
+		}
+	}
+
+	switch {
+	case count == 0:
+		return RegisteredNone, registered
+	case !e.fanOut || count == len(e.list):
+		return RegisteredAll, registered
+	}
+	return RegisteredSome, registered
+}
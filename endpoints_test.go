@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoints(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b")
+	e.now = func() time.Time { return now }
+	assert.NoError(e.add("c"))
+	assert.ErrorIs(e.add("a"), ErrInput)
+
+	assert.Equal([]string{"a", "b", "c"}, e.urls())
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	// A failed endpoint is tried last until the cooldown passes.
+	e.report("a", false, time.Time{})
+	assert.Equal([]string{"b", "c", "a"}, e.order())
+
+	now = now.Add(endpointCooldown)
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	// The cooldown grows with consecutive failures.
+	e.report("a", false, time.Time{})
+	now = now.Add(endpointCooldown)
+	assert.Equal([]string{"b", "c", "a"}, e.order())
+	now = now.Add(endpointCooldown)
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	// The cooldown is capped.
+	for i := 0; i < 20; i++ {
+		e.report("b", false, time.Time{})
+	}
+	now = now.Add(maxEndpointCooldown)
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	// Success clears the failures.
+	e.report("c", false, time.Time{})
+	e.report("c", true, now.Add(time.Hour))
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	// Unknown endpoints are ignored.
+	e.report("d", false, time.Time{})
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+}
+
+func TestEndpoints_Backoff(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b")
+	e.now = func() time.Time { return now }
+
+	// The requested delay extends the cooldown.
+	e.report("a", false, time.Time{})
+	e.backoff("a", 2*endpointCooldown)
+	now = now.Add(endpointCooldown)
+	assert.Equal([]string{"b", "a"}, e.order())
+	now = now.Add(endpointCooldown)
+	assert.Equal([]string{"a", "b"}, e.order())
+
+	// A shorter delay does not shorten the cooldown.
+	e.report("a", false, time.Time{})
+	e.backoff("a", time.Second)
+	now = now.Add(time.Second)
+	assert.Equal([]string{"b", "a"}, e.order())
+
+	// Unknown endpoints are ignored.
+	e.backoff("c", time.Minute)
+	assert.Equal([]string{"a", "b"}, e.urls())
+}
+
+func TestEndpoints_RoundRobin(t *testing.T) {
+	assert := assert.New(t)
+
+	e := newEndpoints("a", "b", "c")
+	e.roundRobin = true
+
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+	assert.Equal([]string{"b", "c", "a"}, e.order())
+	assert.Equal([]string{"c", "a", "b"}, e.order())
+	assert.Equal([]string{"a", "b", "c"}, e.order())
+
+	e.report("b", false, time.Time{})
+	assert.Equal([]string{"c", "a", "b"}, e.order())
+}
+
+func TestEndpoints_Status(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b")
+	e.now = func() time.Time { return now }
+
+	status, registered := e.status()
+	assert.Equal(RegisteredNone, status)
+	assert.Equal(map[string]bool{"a": false, "b": false}, registered)
+	assert.True(e.expiration().IsZero())
+
+	// Without fan out one endpoint holding the registration is enough.
+	e.report("a", true, now.Add(time.Hour))
+	status, _ = e.status()
+	assert.Equal(RegisteredAll, status)
+
+	e.fanOut = true
+	status, registered = e.status()
+	assert.Equal(RegisteredSome, status)
+	assert.Equal(map[string]bool{"a": true, "b": false}, registered)
+
+	e.report("b", true, now.Add(2*time.Hour))
+	status, _ = e.status()
+	assert.Equal(RegisteredAll, status)
+	assert.Equal(now.Add(time.Hour), e.expiration())
+
+	// A failed renewal does not affect a registration that has not expired.
+	e.report("a", true, time.Time{})
+	e.report("b", false, time.Time{})
+	status, _ = e.status()
+	assert.Equal(RegisteredAll, status)
+
+	// Expired registrations are not held.
+	now = now.Add(time.Hour)
+	status, registered = e.status()
+	assert.Equal(RegisteredSome, status)
+	assert.Equal(map[string]bool{"a": false, "b": true}, registered)
+
+	now = now.Add(time.Hour)
+	status, _ = e.status()
+	assert.Equal(RegisteredNone, status)
+}
+
+func TestEndpoints_Due(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b", "c")
+	e.now = func() time.Time { return now }
+
+	// Every endpoint is pending at first.
+	assert.Equal([]string{"a", "b", "c"}, e.due(time.Minute))
+	assert.Equal(time.Minute, e.renewIn(time.Minute))
+
+	e.attempted("a", nil)
+	e.attempted("b", ErrRegistrationFailed)
+	e.attempted("c", nil)
+	e.attempted("d", nil)
+
+	// Only the failed endpoint is retried.
+	now = now.Add(time.Second)
+	assert.Equal([]string{"b"}, e.due(time.Minute))
+
+	e.attempted("b", nil)
+	assert.Empty(e.due(time.Minute))
+	assert.Equal(59*time.Second, e.renewIn(time.Minute))
+
+	// The endpoints are renewed once the interval passes.
+	now = now.Add(59 * time.Second)
+	assert.Equal([]string{"a", "c"}, e.due(time.Minute))
+	assert.Equal(minRenewal, e.renewIn(time.Minute))
+	assert.Equal(time.Millisecond, e.renewIn(time.Millisecond))
+
+	// Restarting registers every endpoint again.
+	e.attempted("a", nil)
+	e.attempted("c", nil)
+	e.restart()
+	assert.Equal([]string{"a", "b", "c"}, e.due(time.Minute))
+}
+
+func TestEndpoints_DueBeforeExpiration(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b")
+	e.now = func() time.Time { return now }
+
+	// The server shortened the registration of a to 10 seconds.
+	e.report("a", true, now.Add(10*time.Second))
+	e.attempted("a", nil)
+	e.report("b", true, now.Add(time.Hour))
+	e.attempted("b", nil)
+
+	assert.Equal(9*time.Second, e.renewIn(time.Minute))
+
+	now = now.Add(9 * time.Second)
+	assert.Equal([]string{"a"}, e.due(time.Minute))
+}
+
+func TestRenewAt(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	assert.Equal(now.Add(time.Minute), renewAt(now, time.Time{}, time.Minute))
+	assert.Equal(now.Add(time.Minute), renewAt(now, now.Add(time.Hour), time.Minute))
+	assert.Equal(now.Add(9*time.Second), renewAt(now, now.Add(10*time.Second), time.Minute))
+
+	// An expired registration is renewed immediately.
+	assert.True(renewAt(now, now.Add(-time.Second), time.Minute).Before(now))
+}
+
+func TestEndpoints_Stopped(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	e := newEndpoints("a", "b")
+	e.now = func() time.Time { return now }
+
+	// A permanent failure stops the endpoint.
+	e.attempted("a", ErrRegistrationRejected)
+	e.attempted("b", ErrRegistrationFailed)
+	assert.True(e.stopped())
+	assert.NoError(e.failure())
+	assert.Equal([]string{"b"}, e.due(time.Minute))
+
+	// The stopped endpoint does not affect the expiration.
+	e.report("b", true, now.Add(time.Hour))
+	e.attempted("b", nil)
+	assert.Equal(now.Add(time.Hour), e.expiration())
+
+	// Once every endpoint is stopped, the failure is permanent.
+	e.attempted("b", classify(http.StatusForbidden))
+	assert.ErrorIs(e.failure(), ErrRegistrationRejected)
+	assert.ErrorIs(e.failure(), ErrRegistrationUnauthorized)
+	assert.Empty(e.due(time.Minute))
+
+	e.restart()
+	assert.False(e.stopped())
+	assert.NoError(e.failure())
+	assert.Equal([]string{"a", "b"}, e.due(time.Minute))
+}
+
+func TestRegistrationStatus_String(t *testing.T) {
+	assert.Equal(t, "none", RegisteredNone.String())
+	assert.Equal(t, "some", RegisteredSome.String())
+	assert.Equal(t, "all", RegisteredAll.String())
+	assert.Equal(t, "unknown", RegistrationStatus(99).String())
+}
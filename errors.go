@@ -17,6 +17,22 @@ var (
 	// ErrRegistrationFailed is returned when the webhook registration fails.
 	ErrRegistrationFailed = errors.New("registration failed")
 
+	// ErrRegistrationUnauthorized is returned with ErrRegistrationFailed when
+	// the webhook server rejects the credentials used for the registration.
+	// The registration is retried with a backoff, since the credentials may
+	// be rotated, unless it is also returned with ErrRegistrationRejected.
+	ErrRegistrationUnauthorized = errors.New("registration unauthorized")
+
+	// ErrRegistrationRejected is returned with ErrRegistrationFailed when the
+	// webhook server rejects the registration itself.  Retrying the same
+	// registration will not succeed.
+	ErrRegistrationRejected = errors.New("registration rejected")
+
+	// ErrRegistrationUnavailable is returned with ErrRegistrationFailed when
+	// the webhook server is unavailable or throttling registrations.  Retrying
+	// later may succeed.
+	ErrRegistrationUnavailable = errors.New("registration unavailable")
+
 	// ErrRegistrationNotAttempted is returned when the webhook registration
 	// was not attempted.
 	ErrRegistrationNotAttempted = errors.New("registration not attempted")
@@ -28,6 +44,11 @@ var (
 	// ErrDecoratorFailed is returned when the decorator returns an error.
 	ErrDecoratorFailed = errors.New("decorator failed")
 
+	// ErrCertificateReloadFailed is reported when a changed client
+	// certificate cannot be loaded.  The previous certificate continues to be
+	// used.
+	ErrCertificateReloadFailed = errors.New("certificate reload failed")
+
 	// ErrNewRequestFailed is returned when the request cannot be created.
 	ErrNewRequestFailed = errors.New("new request failed")
 
@@ -44,6 +65,21 @@ var (
 	// ErrInvalidSignature is returned when the signature is invalid.
 	ErrInvalidSignature = errors.New("invalid signature")
 
+	// ErrInvalidClientCertificate is returned when the client certificate is
+	// missing or not allowed.
+	ErrInvalidClientCertificate = errors.New("invalid client certificate")
+
+	// ErrSourceNotAllowed is returned when the request does not come from an
+	// allowed source address.
+	ErrSourceNotAllowed = errors.New("source not allowed")
+
 	// ErrUnableToReadBody is returned when the body cannot be read.
 	ErrUnableToReadBody = errors.New("unable to read body")
+
+	// ErrUnsupportedContentEncoding is returned when the body uses a content
+	// encoding that cannot be decoded.
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+	// ErrBodyTooLarge is returned when the decoded body exceeds the limit.
+	ErrBodyTooLarge = errors.New("body too large")
 )
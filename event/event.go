@@ -24,9 +24,25 @@ import (
 // The status code of the response may be of interest so it is captured in the
 // event as StatusCode when it occurs.
 //
+// Details the webhook server reports about the registration are captured in the
+// event as ID and Warnings when they are available.  A delay requested by the
+// server before the next attempt is captured as RetryAfter.
+//
+// When a permanent failure stops the registrations, a final event with
+// Terminal set is created.  No more registrations are attempted until the
+// listener is registered or updated again.  When registering with every
+// endpoint, an endpoint that fails permanently is stopped with its own
+// Terminal event while the other endpoints continue to be registered.
+//
 // Any error that occurs during the registration is captured in the event as Err
 // when it occurs.  Multiple error may be included for each event.
 type Registration struct {
+	// Name holds the name of the registration if one was provided.
+	Name string
+
+	// Endpoint holds the webhook registration endpoint used.
+	Endpoint string
+
 	// At holds the starting time of the event if applicable.
 	At time.Time
 
@@ -39,9 +55,25 @@ type Registration struct {
 	// StatusCode holds the HTTP status code returned by the webhook registration.
 	StatusCode int
 
-	// Until holds the time the registration expires if applicable.
+	// Until holds the time the registration expires if applicable.  The
+	// expiration reported by the webhook server is used when available.
 	Until time.Time
 
+	// ID holds the registration identifier assigned by the webhook server if
+	// one was reported.
+	ID string
+
+	// Warnings holds any warnings reported by the webhook server.
+	Warnings []string
+
+	// RetryAfter holds the delay requested by the webhook server before the
+	// next registration attempt if applicable.
+	RetryAfter time.Duration
+
+	// Terminal is true if the registrations stopped because of a permanent
+	// failure.
+	Terminal bool
+
 	// Err holds any error that occurred while performing the registration.
 	Err error
 }
@@ -50,11 +82,17 @@ func (r Registration) String() string {
 	buf := strings.Builder{}
 
 	buf.WriteString("event.Registration{\n")
+	fmt.Fprintf(&buf, "  Name:       '%s'\n", r.Name)
+	fmt.Fprintf(&buf, "  Endpoint:   '%s'\n", r.Endpoint)
 	fmt.Fprintf(&buf, "  At:         %s\n", r.At.Format(time.RFC3339))
 	fmt.Fprintf(&buf, "  Duration:   %s\n", r.Duration.String())
 	fmt.Fprintf(&buf, "  Body:       '%s'\n", string(r.Body))
 	fmt.Fprintf(&buf, "  StatusCode: %d\n", r.StatusCode)
 	fmt.Fprintf(&buf, "  Until:      %s\n", r.Until.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "  ID:         '%s'\n", r.ID)
+	fmt.Fprintf(&buf, "  Warnings:   [%s]\n", strings.Join(r.Warnings, ", "))
+	fmt.Fprintf(&buf, "  RetryAfter: %s\n", r.RetryAfter.String())
+	fmt.Fprintf(&buf, "  Terminal:   %t\n", r.Terminal)
 	fmt.Fprintf(&buf, "  Err:        %v\n", r.Err)
 	buf.WriteString("}\n")
 
@@ -77,8 +115,16 @@ func (f RegistrationFunc) OnRegistrationEvent(r Registration) {
 // Tokenize is an event that occurs during Tokenize() call.
 //
 // When available the header, algorithms, and algorithm used are included.
+// Details about the request being tokenized are included to help identify the
+// sender.  The signature itself is never included.
 // Any error that occurs during tokenization is included.
 type Tokenize struct {
+	// At holds the starting time of the event.
+	At time.Time
+
+	// Duration holds the duration of the event.
+	Duration time.Duration
+
 	// Header holds the header that was used to tokenize the request.
 	Header string
 
@@ -88,6 +134,22 @@ type Tokenize struct {
 	// Algorithm holds the algorithm that was used to tokenize the request.
 	Algorithm string
 
+	// RemoteAddr holds the network address that sent the request.
+	RemoteAddr string
+
+	// Path holds the path of the request URL.
+	Path string
+
+	// ContentLength holds the length of the request body as reported by the
+	// request.  A value of -1 indicates the length is unknown.
+	ContentLength int64
+
+	// ContentType holds the Content-Type header of the request.
+	ContentType string
+
+	// RequestID holds the request identifier provided by the sender if present.
+	RequestID string
+
 	// Err holds any error that occurred while tokenizing the request.
 	Err error
 }
@@ -96,10 +158,17 @@ func (t Tokenize) String() string {
 	buf := strings.Builder{}
 
 	buf.WriteString("event.Tokenize{\n")
-	fmt.Fprintf(&buf, "  Header:     '%s'\n", t.Header)
-	fmt.Fprintf(&buf, "  Algorithms: [%s]\n", strings.Join(t.Algorithms, ", "))
-	fmt.Fprintf(&buf, "  Algorithm:  '%s'\n", t.Algorithm)
-	fmt.Fprintf(&buf, "  Err:        %v\n", t.Err)
+	fmt.Fprintf(&buf, "  At:            %s\n", t.At.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "  Duration:      %s\n", t.Duration.String())
+	fmt.Fprintf(&buf, "  Header:        '%s'\n", t.Header)
+	fmt.Fprintf(&buf, "  Algorithms:    [%s]\n", strings.Join(t.Algorithms, ", "))
+	fmt.Fprintf(&buf, "  Algorithm:     '%s'\n", t.Algorithm)
+	fmt.Fprintf(&buf, "  RemoteAddr:    '%s'\n", t.RemoteAddr)
+	fmt.Fprintf(&buf, "  Path:          '%s'\n", t.Path)
+	fmt.Fprintf(&buf, "  ContentLength: %d\n", t.ContentLength)
+	fmt.Fprintf(&buf, "  ContentType:   '%s'\n", t.ContentType)
+	fmt.Fprintf(&buf, "  RequestID:     '%s'\n", t.RequestID)
+	fmt.Fprintf(&buf, "  Err:           %v\n", t.Err)
 	buf.WriteString("}\n")
 
 	return buf.String()
@@ -119,12 +188,61 @@ func (f TokenizeFunc) OnTokenizeEvent(t Tokenize) {
 }
 
 // AuthorizeEvent is an event that occurs during the Authorize() call.
-// When available the algorithm used is included.
+// When available the algorithm used and the accepted secret that matched are
+// included.  Details about the request being authorized are included to help
+// identify the sender.  Neither the secret nor the signature is ever included.
 // Any error that occurs during authorization is included.
 type Authorize struct {
+	// At holds the starting time of the event.
+	At time.Time
+
+	// Duration holds the duration of the event.
+	Duration time.Duration
+
 	// Algorithm holds the algorithm that was used for authorization.
 	Algorithm string
 
+	// SecretIndex holds the index of the accepted secret that matched the
+	// signature.  A value of -1 indicates no secret matched.
+	SecretIndex int
+
+	// SecretLabel holds the operator supplied label of the accepted secret
+	// that matched the signature if any.
+	SecretLabel string
+
+	// SecretFingerprint holds a fingerprint of the accepted secret that
+	// matched the signature if any.  It is only set when fingerprints are
+	// enabled, since low entropy secrets can be guessed from it.
+	SecretFingerprint string
+
+	// ClientCertificate holds the subject of the client certificate if it
+	// was checked.
+	ClientCertificate string
+
+	// KeyID holds the id of the public key that verified the signature if
+	// the callback was signed with a private key.
+	KeyID string
+
+	// RemoteAddr holds the network address that sent the request.
+	RemoteAddr string
+
+	// ClientIP holds the client address, accounting for trusted proxies, if
+	// the source address was checked.
+	ClientIP string
+
+	// Path holds the path of the request URL.
+	Path string
+
+	// ContentLength holds the length of the request body as reported by the
+	// request.  A value of -1 indicates the length is unknown.
+	ContentLength int64
+
+	// ContentType holds the Content-Type header of the request.
+	ContentType string
+
+	// RequestID holds the request identifier provided by the sender if present.
+	RequestID string
+
 	// Err holds any error that occurred while tokenizing the request.
 	Err error
 }
@@ -133,8 +251,21 @@ func (a Authorize) String() string {
 	buf := strings.Builder{}
 
 	buf.WriteString("event.Authorize{\n")
-	fmt.Fprintf(&buf, "  Algorithm:  '%s'\n", a.Algorithm)
-	fmt.Fprintf(&buf, "  Err:        %v\n", a.Err)
+	fmt.Fprintf(&buf, "  At:                %s\n", a.At.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "  Duration:          %s\n", a.Duration.String())
+	fmt.Fprintf(&buf, "  Algorithm:         '%s'\n", a.Algorithm)
+	fmt.Fprintf(&buf, "  SecretIndex:       %d\n", a.SecretIndex)
+	fmt.Fprintf(&buf, "  SecretLabel:       '%s'\n", a.SecretLabel)
+	fmt.Fprintf(&buf, "  SecretFingerprint: '%s'\n", a.SecretFingerprint)
+	fmt.Fprintf(&buf, "  ClientCertificate: '%s'\n", a.ClientCertificate)
+	fmt.Fprintf(&buf, "  KeyID:             '%s'\n", a.KeyID)
+	fmt.Fprintf(&buf, "  RemoteAddr:        '%s'\n", a.RemoteAddr)
+	fmt.Fprintf(&buf, "  ClientIP:          '%s'\n", a.ClientIP)
+	fmt.Fprintf(&buf, "  Path:              '%s'\n", a.Path)
+	fmt.Fprintf(&buf, "  ContentLength:     %d\n", a.ContentLength)
+	fmt.Fprintf(&buf, "  ContentType:       '%s'\n", a.ContentType)
+	fmt.Fprintf(&buf, "  RequestID:         '%s'\n", a.RequestID)
+	fmt.Fprintf(&buf, "  Err:               %v\n", a.Err)
 	buf.WriteString("}\n")
 
 	return buf.String()
@@ -21,28 +21,54 @@ func TestString(t *testing.T) {
 			description: "Empty Registration",
 			reg:         &Registration{},
 			want: "event.Registration{\n" +
+				"  Name:       ''\n" +
+				"  Endpoint:   ''\n" +
 				"  At:         0001-01-01T00:00:00Z\n" +
 				"  Duration:   0s\n" +
 				"  Body:       ''\n" +
 				"  StatusCode: 0\n" +
 				"  Until:      0001-01-01T00:00:00Z\n" +
+				"  ID:         ''\n" +
+				"  Warnings:   []\n" +
+				"  RetryAfter: 0s\n" +
+				"  Terminal:   false\n" +
 				"  Err:        <nil>\n" +
 				"}\n",
 		}, {
 			description: "Empty Tokenize",
 			token:       &Tokenize{},
 			want: "event.Tokenize{\n" +
-				"  Header:     ''\n" +
-				"  Algorithms: []\n" +
-				"  Algorithm:  ''\n" +
-				"  Err:        <nil>\n" +
+				"  At:            0001-01-01T00:00:00Z\n" +
+				"  Duration:      0s\n" +
+				"  Header:        ''\n" +
+				"  Algorithms:    []\n" +
+				"  Algorithm:     ''\n" +
+				"  RemoteAddr:    ''\n" +
+				"  Path:          ''\n" +
+				"  ContentLength: 0\n" +
+				"  ContentType:   ''\n" +
+				"  RequestID:     ''\n" +
+				"  Err:           <nil>\n" +
 				"}\n",
 		}, {
 			description: "Empty Authorize",
 			auth:        &Authorize{},
 			want: "event.Authorize{\n" +
-				"  Algorithm:  ''\n" +
-				"  Err:        <nil>\n" +
+				"  At:                0001-01-01T00:00:00Z\n" +
+				"  Duration:          0s\n" +
+				"  Algorithm:         ''\n" +
+				"  SecretIndex:       0\n" +
+				"  SecretLabel:       ''\n" +
+				"  SecretFingerprint: ''\n" +
+				"  ClientCertificate: ''\n" +
+				"  KeyID:             ''\n" +
+				"  RemoteAddr:        ''\n" +
+				"  ClientIP:          ''\n" +
+				"  Path:              ''\n" +
+				"  ContentLength:     0\n" +
+				"  ContentType:       ''\n" +
+				"  RequestID:         ''\n" +
+				"  Err:               <nil>\n" +
 				"}\n",
 		},
 	}
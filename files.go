@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies a version of a file by its modification time and size.
+// A file that cannot be read has the zero stamp.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchedFiles provides a value loaded from files and loads it again whenever
+// any of the files change.  If loading the changed files fails, the previous
+// value continues to be used and the failure is only returned once for each
+// change, so a broken file is not read again until it changes.
+type watchedFiles[T any] struct {
+	paths []string
+	load  func() (T, error)
+
+	m      sync.Mutex
+	stamps []fileStamp
+	loaded bool
+	value  T
+	err    error
+}
+
+// newWatchedFiles creates a watchedFiles that uses the load function to load
+// the value from the files.
+func newWatchedFiles[T any](load func() (T, error), paths ...string) *watchedFiles[T] {
+	return &watchedFiles[T]{
+		paths: paths,
+		load:  load,
+	}
+}
+
+// get returns the value, loading it again first if the files changed.  The
+// error is returned if loading the changed files failed, along with the
+// previous value if there is one.  Until a value is loaded, the error is
+// returned by every call.
+func (w *watchedFiles[T]) get() (T, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	stamps := make([]fileStamp, len(w.paths))
+	var statErr error
+	for i, path := range w.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			statErr = err
+			continue
+		}
+		stamps[i] = fileStamp{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+	}
+
+	if w.stamps != nil && w.unchanged(stamps) {
+		if w.loaded {
+			return w.value, nil
+		}
+		return w.value, w.err
+	}
+	w.stamps = stamps
+
+	w.err = statErr
+	if w.err == nil {
+		var value T
+		value, w.err = w.load()
+		if w.err == nil {
+			w.value = value
+			w.loaded = true
+		}
+	}
+
+	return w.value, w.err
+}
+
+// unchanged returns true if the stamps match the stamps of the last load.
+// The lock must be held by the caller.
+func (w *watchedFiles[T]) unchanged(stamps []fileStamp) bool {
+	for i := range stamps {
+		if !stamps[i].modTime.Equal(w.stamps[i].modTime) || stamps[i].size != w.stamps[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedFiles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "value")
+	errInvalid := errors.New("invalid")
+
+	loads := 0
+	w := newWatchedFiles(func() (string, error) {
+		loads++
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if string(b) == "invalid" {
+			return "", errInvalid
+		}
+		return string(b), nil
+	}, path)
+
+	// A missing file is an error until it exists.
+	_, err := w.get()
+	assert.Error(err)
+	_, err = w.get()
+	assert.Error(err)
+
+	mod := time.Now().Add(-time.Hour)
+	writeFile(t, path, []byte("one"), mod)
+
+	v, err := w.get()
+	require.NoError(err)
+	assert.Equal("one", v)
+	assert.Equal(1, loads)
+
+	// An unchanged file is not loaded again.
+	v, err = w.get()
+	require.NoError(err)
+	assert.Equal("one", v)
+	assert.Equal(1, loads)
+
+	// A change in size is detected even if the modification time is the same.
+	writeFile(t, path, []byte("three"), mod)
+
+	v, err = w.get()
+	require.NoError(err)
+	assert.Equal("three", v)
+	assert.Equal(2, loads)
+
+	// A failure is only returned once and the previous value is kept.
+	mod = mod.Add(time.Minute)
+	writeFile(t, path, []byte("invalid"), mod)
+
+	v, err = w.get()
+	assert.ErrorIs(err, errInvalid)
+	assert.Equal("three", v)
+
+	v, err = w.get()
+	assert.NoError(err)
+	assert.Equal("three", v)
+	assert.Equal(3, loads)
+
+	// The next change is loaded.
+	mod = mod.Add(time.Minute)
+	writeFile(t, path, []byte("four"), mod)
+
+	v, err = w.get()
+	require.NoError(err)
+	assert.Equal("four", v)
+}
@@ -10,7 +10,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -297,6 +299,58 @@ func TestSingleShotUsage(t *testing.T) {
 	whl.Stop()
 }
 
+func TestEventListenersCallBack(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	whl, err := New(
+		server.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		Once(),
+		AsyncEvents(1, OverflowBlock),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	// The event listeners may use the listener while it is registering.
+	var count atomic.Int32
+	whl.AddRegistrationEventListener(event.RegistrationFunc(
+		func(e event.Registration) {
+			assert.NotEmpty(whl.String())
+			assert.NoError(whl.Err())
+			assert.NotNil(whl.Health().Endpoints)
+			count.Add(1)
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			assert.NoError(whl.Register(context.Background()))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		require.FailNow("the registration deadlocked")
+	}
+
+	whl.Stop()
+	assert.Equal(int32(5), count.Load())
+}
+
 func TestFailedHTTPCall(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -541,10 +595,16 @@ func TestFailsAfterABit(t *testing.T) {
 	require.NotNil(whl)
 	require.NoError(err)
 
+	var terminal atomic.Int32
 	cancel := whl.AddRegistrationEventListener(event.RegistrationFunc(
 		func(e event.Registration) {
-			assert.NotZero(e.StatusCode)
 			assert.NotZero(e.At)
+			if e.Terminal {
+				terminal.Add(1)
+				assert.ErrorIs(e.Err, ErrRegistrationRejected)
+				return
+			}
+			assert.NotZero(e.StatusCode)
 			assert.NotZero(e.Duration)
 			if e.StatusCode == http.StatusOK {
 				assert.NoError(e.Err)
@@ -559,8 +619,647 @@ func TestFailsAfterABit(t *testing.T) {
 	err = whl.Register(context.Background())
 	assert.NoError(err)
 
-	// Wait a bit then roll the secret..
-	time.Sleep(10 * time.Millisecond)
+	terminated := func(n int32) func() bool {
+		return func() bool { return terminal.Load() == n }
+	}
+
+	// The rejected registration is not retried.
+	require.Eventually(terminated(1), time.Second, time.Millisecond)
+	m.Lock()
+	assert.Equal(2, count)
+	m.Unlock()
+	assert.ErrorIs(whl.Err(), ErrRegistrationRejected)
+	assert.Equal(whl.Err().Error(), whl.Health().Err)
+
+	// Registering again restarts the registrations.
+	err = whl.Register(context.Background())
+	assert.NoError(err)
+	require.Eventually(terminated(2), time.Second, time.Millisecond)
+
+	m.Lock()
+	assert.Equal(3, count)
+	m.Unlock()
+
+	// Updating the registration also restarts the registrations.
+	err = whl.Update(&webhook.Registration{
+		Events: []string{
+			"bar",
+		},
+		Duration: webhook.CustomDuration(5 * time.Minute),
+	})
+	assert.NoError(err)
+	require.Eventually(terminated(3), time.Second, time.Millisecond)
+
+	m.Lock()
+	assert.Equal(4, count)
+	m.Unlock()
 
 	whl.Stop()
 }
+
+func TestUnauthorizedIsRetried(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	mod := time.Now()
+	writeFile(t, tokenFile, []byte("old"), mod)
+
+	var count atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				count.Add(1)
+				if r.Header.Get("Authorization") != "Bearer new" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	var terminal atomic.Int32
+	whl, err := New(
+		server.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		Interval(time.Minute),
+		DecorateRequest(BearerAuth(FileCredential(tokenFile))),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				if e.Terminal {
+					terminal.Add(1)
+				}
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	defer whl.Stop()
+
+	require.Eventually(func() bool {
+		return count.Load() >= 1
+	}, 5*time.Second, time.Millisecond)
+
+	// The rotated token is used once the registration is retried.
+	writeFile(t, tokenFile, []byte("new"), mod.Add(time.Minute))
+	require.Eventually(func() bool {
+		return whl.Health().Status == RegisteredAll
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(whl.Err())
+	assert.Zero(terminal.Load())
+}
+
+func TestUpdateRunningListener(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	regs := make(chan webhook.Registration, 10)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(err)
+				r.Body.Close()
+
+				var reg webhook.Registration
+				err = json.Unmarshal(body, &reg)
+				assert.NoError(err)
+				regs <- reg
+
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	// Create the listener with a long interval so only the initial and
+	// updated registrations happen.
+	whl, err := New(
+		server.URL,
+		&webhook.Registration{
+			Events: []string{
+				"foo",
+			},
+			Config: webhook.DeliveryConfig{
+				Secret: "secret1",
+			},
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		Interval(time.Hour),
+		WebhookOpts(webhook.AtLeastOneEvent()),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	defer whl.Stop()
+
+	got := <-regs
+	assert.Equal([]string{"foo"}, got.Events)
+	assert.Equal("secret1", got.Config.Secret)
+
+	// The webhook options from New still apply.
+	err = whl.Update(&webhook.Registration{
+		Duration: webhook.CustomDuration(10 * time.Minute),
+	})
+	assert.ErrorIs(err, ErrInput)
+
+	err = whl.Update(
+		&webhook.Registration{
+			Events: []string{
+				"bar",
+			},
+			Duration: webhook.CustomDuration(10 * time.Minute),
+		},
+		Interval(2*time.Hour),
+	)
+	require.NoError(err)
+
+	select {
+	case got = <-regs:
+	case <-time.After(5 * time.Second):
+		require.Fail("the update did not cause a registration")
+	}
+
+	assert.Equal([]string{"bar"}, got.Events)
+	assert.Equal("secret1", got.Config.Secret)
+	assert.Equal(webhook.CustomDuration(10*time.Minute), got.Duration)
+	assert.Equal(2*time.Hour, whl.getInterval())
+
+	// A running listener cannot be changed to only register once.
+	err = whl.Update(&webhook.Registration{
+		Events:   []string{"bar"},
+		Duration: webhook.CustomDuration(10 * time.Minute),
+	}, Once())
+	assert.ErrorIs(err, ErrInput)
+}
+
+func TestFailoverEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	down := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		),
+	)
+	defer down.Close()
+
+	rejects := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+		),
+	)
+	defer rejects.Close()
+
+	up := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			},
+		),
+	)
+	defer up.Close()
+
+	var events []event.Registration
+
+	whl, err := New(
+		down.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(up.URL, rejects.URL),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	// The unavailable endpoint fails over to the next one.
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	require.Len(events, 2)
+	assert.Equal(down.URL, events[0].Endpoint)
+	assert.Equal(http.StatusServiceUnavailable, events[0].StatusCode)
+	assert.ErrorIs(events[0].Err, ErrRegistrationFailed)
+	assert.ErrorIs(events[0].Err, ErrRegistrationUnavailable)
+	assert.Equal(up.URL, events[1].Endpoint)
+	assert.NoError(events[1].Err)
+
+	// The failed endpoint is now tried last.
+	events = nil
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	require.Len(events, 1)
+	assert.Equal(up.URL, events[0].Endpoint)
+
+	// A rejected registration does not fail over.
+	whl, err = New(
+		rejects.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(up.URL),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	events = nil
+	err = whl.Register(context.Background())
+	assert.ErrorIs(err, ErrRegistrationFailed)
+	assert.ErrorIs(err, ErrRegistrationRejected)
+	require.Len(events, 1)
+	assert.Equal(rejects.URL, events[0].Endpoint)
+}
+
+func TestFanOutEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var m sync.Mutex
+	failing := true
+
+	dc1 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer dc1.Close()
+
+	dc2 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				m.Lock()
+				defer m.Unlock()
+				if failing {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer dc2.Close()
+
+	var events []event.Registration
+
+	whl, err := New(
+		dc1.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(dc2.URL),
+		FanOut(),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				m.Lock()
+				defer m.Unlock()
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	health := func() (int, Health) {
+		rec := httptest.NewRecorder()
+		whl.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		var h struct {
+			Status    string          `json:"status"`
+			Endpoints map[string]bool `json:"endpoints"`
+		}
+		assert.NoError(json.Unmarshal(rec.Body.Bytes(), &h))
+		assert.Equal(whl.Health().Status.String(), h.Status)
+		assert.Equal(whl.Health().Endpoints, h.Endpoints)
+		return rec.Code, whl.Health()
+	}
+
+	code, h := health()
+	assert.Equal(http.StatusServiceUnavailable, code)
+	assert.Equal(RegisteredNone, h.Status)
+
+	// One datacenter fails so the registration is only partially complete.
+	err = whl.Register(context.Background())
+	assert.ErrorIs(err, ErrRegistrationFailed)
+
+	m.Lock()
+	require.Len(events, 2)
+	byEndpoint := map[string]event.Registration{}
+	for _, e := range events {
+		byEndpoint[e.Endpoint] = e
+	}
+	m.Unlock()
+	assert.NoError(byEndpoint[dc1.URL].Err)
+	assert.Equal(http.StatusServiceUnavailable, byEndpoint[dc2.URL].StatusCode)
+
+	code, h = health()
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(RegisteredSome, h.Status)
+	assert.Equal(map[string]bool{dc1.URL: true, dc2.URL: false}, h.Endpoints)
+
+	// Once every datacenter accepts the registration, all are registered.
+	m.Lock()
+	failing = false
+	events = nil
+	m.Unlock()
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+
+	m.Lock()
+	assert.Len(events, 2)
+	m.Unlock()
+
+	code, h = health()
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(RegisteredAll, h.Status)
+}
+
+func TestFanOutRetriesFailedEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var dc1Count, dc2Count atomic.Int32
+
+	dc1 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				dc1Count.Add(1)
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer dc1.Close()
+
+	// The second datacenter is unavailable for the first two attempts.
+	dc2 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if dc2Count.Add(1) <= 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer dc2.Close()
+
+	whl, err := New(
+		dc1.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(dc2.URL),
+		FanOut(),
+		Interval(time.Minute),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	defer whl.Stop()
+
+	// Only the failed datacenter is retried.
+	require.Eventually(func() bool {
+		return whl.Health().Status == RegisteredAll
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(int32(3), dc2Count.Load())
+	assert.Equal(int32(1), dc1Count.Load())
+}
+
+func TestFanOutStopsRejectedEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var dc1Count, dc2Count atomic.Int32
+	var dc1Rejects atomic.Bool
+
+	dc1 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				dc1Count.Add(1)
+				if dc1Rejects.Load() {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer dc1.Close()
+
+	dc2 := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				dc2Count.Add(1)
+				w.WriteHeader(http.StatusBadRequest)
+			},
+		),
+	)
+	defer dc2.Close()
+
+	var m sync.Mutex
+	var terminal []event.Registration
+
+	whl, err := New(
+		dc1.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(dc2.URL),
+		FanOut(),
+		Interval(10*time.Millisecond),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				if !e.Terminal {
+					return
+				}
+				m.Lock()
+				defer m.Unlock()
+				terminal = append(terminal, e)
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	terminated := func(n int) func() bool {
+		return func() bool {
+			m.Lock()
+			defer m.Unlock()
+			return len(terminal) == n
+		}
+	}
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	defer whl.Stop()
+
+	// The rejecting datacenter is stopped while the other is still renewed.
+	require.Eventually(func() bool {
+		return dc1Count.Load() >= 3
+	}, 5*time.Second, time.Millisecond)
+	require.True(terminated(1)())
+	m.Lock()
+	assert.Equal(dc2.URL, terminal[0].Endpoint)
+	assert.ErrorIs(terminal[0].Err, ErrRegistrationRejected)
+	m.Unlock()
+	assert.Equal(int32(1), dc2Count.Load())
+	assert.NoError(whl.Err())
+	assert.Equal(RegisteredSome, whl.Health().Status)
+
+	// Once every datacenter rejects the registration, the registrations stop.
+	dc1Rejects.Store(true)
+	require.Eventually(terminated(2), 5*time.Second, time.Millisecond)
+	assert.ErrorIs(whl.Err(), ErrRegistrationRejected)
+
+	// Registering again restarts every datacenter.
+	dc1Rejects.Store(false)
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	require.Eventually(func() bool {
+		return dc2Count.Load() == 2
+	}, 5*time.Second, time.Millisecond)
+	require.Eventually(func() bool {
+		return whl.Err() == nil
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestRenewsBeforeServerExpiration(t *testing.T) {
+	tests := []struct {
+		description string
+		opts        []Option
+	}{
+		{
+			description: "failover",
+		}, {
+			description: "fan out",
+			opts:        []Option{FanOut()},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require := require.New(t)
+
+			// The server only keeps the registration for a second.
+			var count atomic.Int32
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						count.Add(1)
+						w.Header().Set("Content-Type", "application/json")
+						fmt.Fprintf(w, `{"until": "%s"}`,
+							time.Now().Add(time.Second).Format(time.RFC3339Nano))
+					},
+				),
+			)
+			defer server.Close()
+
+			whl, err := New(
+				server.URL,
+				&webhook.Registration{
+					Duration: webhook.CustomDuration(5 * time.Minute),
+				},
+				append(tc.opts, Interval(time.Hour))...,
+			)
+			require.NotNil(whl)
+			require.NoError(err)
+
+			err = whl.Register(context.Background())
+			require.NoError(err)
+			defer whl.Stop()
+
+			require.Eventually(func() bool {
+				return count.Load() >= 3
+			}, 5*time.Second, time.Millisecond)
+			require.Equal(RegisteredAll, whl.Health().Status)
+		})
+	}
+}
+
+func TestRegistrationResponse(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	throttled := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "120")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		),
+	)
+	defer throttled.Close()
+
+	up := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"id": "reg-1", "until": "%s", "warnings": ["deprecated field"]}`,
+					until.Format(time.RFC3339))
+			},
+		),
+	)
+	defer up.Close()
+
+	var events []event.Registration
+
+	whl, err := New(
+		throttled.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AlternateURLs(up.URL),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NotNil(whl)
+	require.NoError(err)
+
+	err = whl.Register(context.Background())
+	require.NoError(err)
+	require.Len(events, 2)
+
+	assert.Equal(throttled.URL, events[0].Endpoint)
+	assert.Equal(http.StatusTooManyRequests, events[0].StatusCode)
+	assert.Equal(2*time.Minute, events[0].RetryAfter)
+	assert.ErrorIs(events[0].Err, ErrRegistrationFailed)
+
+	// The expiration reported by the server is preferred.
+	assert.Equal(up.URL, events[1].Endpoint)
+	assert.Equal("reg-1", events[1].ID)
+	assert.Equal([]string{"deprecated field"}, events[1].Warnings)
+	assert.True(until.Equal(events[1].Until))
+	assert.NoError(events[1].Err)
+}
@@ -6,9 +6,9 @@ require (
 	github.com/stretchr/testify v1.12.1
 	github.com/xmidt-org/eventor v1.0.50
 	github.com/xmidt-org/webhook-schema v0.1.0
+	go.yaml.in/yaml/v3 v3.0.5
 )
 
 require (
 	github.com/xmidt-org/urlegit v0.1.0 // indirect
-	go.yaml.in/yaml/v3 v3.0.5 // indirect
 )
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RegistrationStatus is the aggregate status of the webhook registration
+// across the registration endpoints.
+type RegistrationStatus int
+
+const (
+	// RegisteredNone indicates no endpoint holds the registration.
+	RegisteredNone RegistrationStatus = iota
+
+	// RegisteredSome indicates some, but not all, of the endpoints hold the
+	// registration.  This is only possible when FanOut() is used.
+	RegisteredSome
+
+	// RegisteredAll indicates the registration is held by every endpoint
+	// that needs it.
+	RegisteredAll
+)
+
+func (s RegistrationStatus) String() string {
+	switch s {
+	case RegisteredNone:
+		return "none"
+	case RegisteredSome:
+		return "some"
+	case RegisteredAll:
+		return "all"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the status as text so it is readable in JSON.
+func (s RegistrationStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Health describes the registration health of a Listener.
+type Health struct {
+	// Status is the aggregate registration status.
+	Status RegistrationStatus `json:"status"`
+
+	// Endpoints reports whether each registration endpoint holds a
+	// registration that has not expired.  A failed renewal does not change
+	// this until the previous registration expires.
+	Endpoints map[string]bool `json:"endpoints"`
+
+	// Err describes the permanent failure that stopped the registrations if
+	// there is one.
+	Err string `json:"error,omitempty"`
+}
+
+// Health returns the registration health of the webhook listener.
+func (l *Listener) Health() Health {
+	status, endpoints := l.endpoints.status()
+	h := Health{
+		Status:    status,
+		Endpoints: endpoints,
+	}
+	if err := l.Err(); err != nil {
+		h.Err = err.Error()
+	}
+	return h
+}
+
+// Err returns the permanent failure that stopped the registrations of a running
+// webhook listener, or nil if there is none.  A permanent failure is one where
+// the webhook server rejected the registration or forbade the credentials
+// used, so retrying the same registration will not succeed.  Unauthorized
+// registrations are retried, since the credentials may be rotated.
+//
+// The registrations are restarted by calling Register() or Update(), which
+// clears the failure once the next attempt starts.
+func (l *Listener) Err() error {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	return l.failure
+}
+
+// HealthHandler returns an http.Handler that reports the registration health
+// of the webhook listener as JSON.  The status code is 200 unless no endpoint
+// holds the registration, in which case it is 503.
+func (l *Listener) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		h := l.Health()
+
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status == RegisteredNone {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultJWTLifetime is how long a minted JWT is valid if no lifetime is
+// configured.
+const defaultJWTLifetime = 5 * time.Minute
+
+// JWTConfig configures the JWTs minted for the registration requests.
+type JWTConfig struct {
+	// Key is the private key used to sign the JWTs.  The signing algorithm is
+	// determined by the key: *rsa.PrivateKey uses RS256, *ecdsa.PrivateKey
+	// with the P-256 curve uses ES256 and ed25519.PrivateKey uses EdDSA.
+	// Required.
+	Key crypto.Signer
+
+	// KeyID is the optional key id included in the JWT header.
+	KeyID string
+
+	// Issuer is the optional iss claim.
+	Issuer string
+
+	// Subject is the optional sub claim.
+	Subject string
+
+	// Audience is the optional aud claim.
+	Audience []string
+
+	// Claims are additional claims to include.  The registered claims set by
+	// the other fields (iss, sub, aud, iat, nbf, exp and jti) take precedence.
+	Claims map[string]any
+
+	// Lifetime is how long each JWT is valid.  If 0, 5 minutes is used.
+	Lifetime time.Duration
+
+	// RefreshAhead is how long before the JWT expires that a new JWT is
+	// minted.  If 0, a quarter of the lifetime is used.  It must be less
+	// than the lifetime.
+	RefreshAhead time.Duration
+}
+
+// JWT is a Decorator that authenticates the registration requests with short
+// lived JWTs signed by a private key.  Each JWT is cached and reused until it
+// is close to expiring.
+type JWT struct {
+	cfg JWTConfig
+	alg string
+	now func() time.Time
+
+	m       sync.Mutex
+	token   string
+	expires time.Time
+}
+
+var _ Refresher = (*JWT)(nil)
+
+// NewJWT creates a new JWT decorator.
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	alg, err := jwtAlgorithm(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Lifetime < 0 || cfg.RefreshAhead < 0 {
+		return nil, fmt.Errorf("%w: lifetime and refresh ahead must be greater than or equal to 0", ErrInput)
+	}
+	if cfg.Lifetime == 0 {
+		cfg.Lifetime = defaultJWTLifetime
+	}
+	if cfg.RefreshAhead == 0 {
+		cfg.RefreshAhead = cfg.Lifetime / 4
+	}
+	if cfg.RefreshAhead >= cfg.Lifetime {
+		return nil, fmt.Errorf("%w: refresh ahead must be less than the lifetime", ErrInput)
+	}
+
+	return &JWT{
+		cfg: cfg,
+		alg: alg,
+		now: time.Now,
+	}, nil
+}
+
+// jwtAlgorithm returns the JWS algorithm used with the key.
+func jwtAlgorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return "", fmt.Errorf("%w: rsa keys must be at least 2048 bits", ErrInput)
+		}
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: only the P-256 curve is supported", ErrInput)
+		}
+		return "ES256", nil
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	case nil:
+		return "", fmt.Errorf("%w: a signing key is required", ErrInput)
+	}
+	return "", fmt.Errorf("%w: unsupported signing key type %T", ErrInput, key)
+}
+
+// Decorate adds a JWT to the Authorization header of the request, minting a
+// new JWT if needed.
+func (j *JWT) Decorate(r *http.Request) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	now := j.now()
+	if j.token == "" || !now.Add(j.cfg.RefreshAhead).Before(j.expires) {
+		token, err := j.mint(now)
+		if err != nil {
+			return fmt.Errorf("%w: unable to mint the jwt: %w", ErrDecoratorFailed, err)
+		}
+		j.token = token
+		j.expires = now.Add(j.cfg.Lifetime)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+j.token)
+	return nil
+}
+
+// Refresh discards the cached JWT so a new JWT is minted by the next call to
+// Decorate.
+func (j *JWT) Refresh() {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	j.token = ""
+}
+
+func (j *JWT) String() string {
+	return "JWT(" + j.alg + ", " + j.cfg.Issuer + ")"
+}
+
+// mint creates and signs a new JWT issued at the time provided.
+func (j *JWT) mint(now time.Time) (string, error) {
+	header := map[string]string{
+		"alg": j.alg,
+		"typ": "JWT",
+	}
+	if j.cfg.KeyID != "" {
+		header["kid"] = j.cfg.KeyID
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	claims := make(map[string]any, len(j.cfg.Claims)+7)
+	for k, v := range j.cfg.Claims {
+		claims[k] = v
+	}
+	if j.cfg.Issuer != "" {
+		claims["iss"] = j.cfg.Issuer
+	}
+	if j.cfg.Subject != "" {
+		claims["sub"] = j.cfg.Subject
+	}
+	switch len(j.cfg.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = j.cfg.Audience[0]
+	default:
+		claims["aud"] = j.cfg.Audience
+	}
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(j.cfg.Lifetime).Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(h) + "." +
+		base64.RawURLEncoding.EncodeToString(c)
+
+	sig, err := j.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// sign signs the JWS signing input using the algorithm of the key.
+func (j *JWT) sign(input []byte) ([]byte, error) {
+	switch k := j.cfg.Key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, input), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed size concatenation of r and s.
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+
+	digest := sha256.Sum256(input)
+	return j.cfg.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
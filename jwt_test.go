@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyJWT verifies the signature of the JWT and returns the header and
+// claims.
+func verifyJWT(t *testing.T, token string, pub crypto.PublicKey) (map[string]any, map[string]any) {
+	require := require.New(t)
+
+	parts := strings.Split(token, ".")
+	require.Len(parts, 3)
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(err)
+
+	input := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(input)
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		require.True(ed25519.Verify(k, input, sig))
+	case *ecdsa.PublicKey:
+		require.Len(sig, 64)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		require.True(ecdsa.Verify(k, digest[:], r, s))
+	case *rsa.PublicKey:
+		require.NoError(rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig))
+	default:
+		require.Fail("unknown key type")
+	}
+
+	decode := func(s string) map[string]any {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		require.NoError(err)
+		m := map[string]any{}
+		require.NoError(json.Unmarshal(b, &m))
+		return m
+	}
+
+	return decode(parts[0]), decode(parts[1])
+}
+
+func TestJWT(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		description string
+		key         crypto.Signer
+		alg         string
+	}{
+		{
+			description: "EdDSA",
+			key:         edKey,
+			alg:         "EdDSA",
+		}, {
+			description: "ES256",
+			key:         ecKey,
+			alg:         "ES256",
+		}, {
+			description: "RS256",
+			key:         rsaKey,
+			alg:         "RS256",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			j, err := NewJWT(JWTConfig{
+				Key:      tc.key,
+				KeyID:    "key-1",
+				Issuer:   "issuer",
+				Subject:  "subject",
+				Audience: []string{"webhooks"},
+				Claims: map[string]any{
+					"scope": "register",
+					"iss":   "ignored",
+				},
+			})
+			require.NoError(err)
+			assert.Equal("JWT("+tc.alg+", issuer)", j.String())
+
+			now := time.Unix(1700000000, 0)
+			j.now = func() time.Time { return now }
+
+			decorate := func() string {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+				require.NoError(j.Decorate(r))
+				token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				require.True(found)
+				return token
+			}
+
+			token := decorate()
+			header, claims := verifyJWT(t, token, tc.key.Public())
+			assert.Equal(map[string]any{"alg": tc.alg, "typ": "JWT", "kid": "key-1"}, header)
+			assert.Equal("issuer", claims["iss"])
+			assert.Equal("subject", claims["sub"])
+			assert.Equal("webhooks", claims["aud"])
+			assert.Equal("register", claims["scope"])
+			assert.Equal(float64(now.Unix()), claims["iat"])
+			assert.Equal(float64(now.Unix()), claims["nbf"])
+			assert.Equal(float64(now.Add(defaultJWTLifetime).Unix()), claims["exp"])
+			assert.NotEmpty(claims["jti"])
+
+			// The JWT is cached until it is close to expiring.
+			now = now.Add(defaultJWTLifetime/4*3 - time.Second)
+			assert.Equal(token, decorate())
+			now = now.Add(time.Second)
+			next := decorate()
+			assert.NotEqual(token, next)
+
+			// Refresh discards the cached JWT.
+			j.Refresh()
+			assert.NotEqual(next, decorate())
+		})
+	}
+}
+
+func TestNewJWT(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	small, err := rsa.GenerateKey(rand.Reader, 1024) // nolint: gosec
+	require.NoError(t, err)
+
+	tests := []struct {
+		description string
+		cfg         JWTConfig
+		expectedErr error
+	}{
+		{
+			description: "multiple audiences",
+			cfg: JWTConfig{
+				Key:      edKey,
+				Audience: []string{"a", "b"},
+				Lifetime: time.Minute,
+			},
+		}, {
+			description: "no key",
+			expectedErr: ErrInput,
+		}, {
+			description: "unsupported curve",
+			cfg:         JWTConfig{Key: p384},
+			expectedErr: ErrInput,
+		}, {
+			description: "small rsa key",
+			cfg:         JWTConfig{Key: small},
+			expectedErr: ErrInput,
+		}, {
+			description: "negative lifetime",
+			cfg:         JWTConfig{Key: edKey, Lifetime: -1},
+			expectedErr: ErrInput,
+		}, {
+			description: "refresh ahead longer than the lifetime",
+			cfg: JWTConfig{
+				Key:          edKey,
+				Lifetime:     time.Minute,
+				RefreshAhead: time.Minute,
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			j, err := NewJWT(tc.cfg)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(j)
+				return
+			}
+			require.NoError(t, err)
+
+			r, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+			require.NoError(t, j.Decorate(r))
+
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			_, claims := verifyJWT(t, token, tc.cfg.Key.Public())
+			assert.Equal([]any{"a", "b"}, claims["aud"])
+			assert.NotContains(claims, "iss")
+		})
+	}
+}
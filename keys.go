@@ -0,0 +1,336 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// The algorithms used for callbacks signed with a private key.  The signature
+// header uses the same <algorithm>=<value> format as the HMAC signatures.
+// The value is the hex, base64 or base64url encoded signature, optionally
+// prefixed with the key id and a colon: ed25519=<key id>:<signature>.
+const (
+	// Ed25519Algorithm is the algorithm for Ed25519 signatures of the body.
+	Ed25519Algorithm = "ed25519"
+
+	// ES256Algorithm is the algorithm for ECDSA P-256 signatures of the
+	// SHA-256 hash of the body.  The signature may be the 64 byte
+	// concatenation of r and s or ASN.1 encoded.
+	ES256Algorithm = "es256"
+)
+
+// PublicKey is a public key used to verify the webhook callbacks.
+type PublicKey struct {
+	// ID is the key id used to select the key.  Keys without an id are
+	// only used for signatures without a key id.
+	ID string
+
+	// Key is the public key, which must be an ed25519.PublicKey or an
+	// *ecdsa.PublicKey using the P-256 curve.
+	Key crypto.PublicKey
+}
+
+// algorithm returns the signature algorithm used with the key.
+func (k PublicKey) algorithm() (string, error) {
+	switch key := k.Key.(type) {
+	case ed25519.PublicKey:
+		if len(key) != ed25519.PublicKeySize {
+			return "", fmt.Errorf("%w: invalid ed25519 key '%s'", ErrInput, k.ID)
+		}
+		return Ed25519Algorithm, nil
+	case *ecdsa.PublicKey:
+		if key == nil || key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: key '%s' must use the P-256 curve", ErrInput, k.ID)
+		}
+		return ES256Algorithm, nil
+	}
+	return "", fmt.Errorf("%w: unsupported key type %T for key '%s'", ErrInput, k.Key, k.ID)
+}
+
+// AcceptPublicKeys is an option that enables verifying webhook callbacks
+// signed with the private keys matching the public keys provided.  The key id
+// included with the signature selects the key to use.  Multiple keys may be
+// provided to allow for rotation.
+func AcceptPublicKeys(keys ...PublicKey) Option {
+	return &acceptPublicKeysOption{
+		keys: keys,
+	}
+}
+
+type acceptPublicKeysOption struct {
+	keys []PublicKey
+}
+
+func (a acceptPublicKeysOption) apply(lis *Listener) error {
+	if len(a.keys) == 0 {
+		return fmt.Errorf("%w: at least one public key is required", ErrInput)
+	}
+
+	for _, k := range a.keys {
+		alg, err := k.algorithm()
+		if err != nil {
+			return err
+		}
+		lis.acceptAlgorithm(alg)
+	}
+
+	lis.publicKeys.static = append(lis.publicKeys.static, a.keys...)
+	return nil
+}
+
+func (a acceptPublicKeysOption) String() string {
+	ids := make([]string, 0, len(a.keys))
+	for _, k := range a.keys {
+		ids = append(ids, k.ID)
+	}
+	return "AcceptPublicKeys(" + strings.Join(ids, ", ") + ")"
+}
+
+// AcceptJWKSFile is an option that enables verifying webhook callbacks using
+// the public keys in the JSON Web Key Set file.  Ed25519 (OKP) and P-256 (EC)
+// keys are supported; other keys are ignored.  The file is read again whenever
+// it changes, so keys can be rotated without restarting the listener.
+func AcceptJWKSFile(path string) Option {
+	return &acceptJWKSFileOption{
+		path: path,
+	}
+}
+
+type acceptJWKSFileOption struct {
+	path string
+}
+
+func (a acceptJWKSFileOption) apply(lis *Listener) error {
+	if lis.publicKeys.file != nil {
+		return fmt.Errorf("%w: only one jwks file may be provided", ErrInput)
+	}
+
+	f := newJWKSFile(a.path)
+	if _, err := f.load(); err != nil {
+		return errors.Join(err, fmt.Errorf("%w: unable to load the jwks file", ErrInput))
+	}
+
+	lis.acceptAlgorithm(Ed25519Algorithm)
+	lis.acceptAlgorithm(ES256Algorithm)
+	lis.publicKeys.file = f
+	return nil
+}
+
+func (a acceptJWKSFileOption) String() string {
+	return "AcceptJWKSFile(" + a.path + ")"
+}
+
+// acceptAlgorithm adds the algorithm to the preferred algorithms if it is not
+// already present.
+func (l *Listener) acceptAlgorithm(alg string) {
+	for _, existing := range l.hashPreferences {
+		if existing == alg {
+			return
+		}
+	}
+	l.hashPreferences = append(l.hashPreferences, alg)
+}
+
+// publicKeys is the set of public keys accepted by the Listener.
+type publicKeys struct {
+	static []PublicKey
+	file   *jwksFile
+}
+
+// keys returns the accepted keys for the algorithm.
+func (pk *publicKeys) keys(alg string) ([]PublicKey, error) {
+	all := pk.static
+	if pk.file != nil {
+		fromFile, err := pk.file.load()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all[:len(all):len(all)], fromFile...)
+	}
+
+	keys := make([]PublicKey, 0, len(all))
+	for _, k := range all {
+		if a, err := k.algorithm(); err == nil && a == alg {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// verify verifies the signature of the body.  The value is the encoded
+// signature optionally prefixed by the key id and a colon.  The id of the key
+// that verified the signature is returned.
+func (pk *publicKeys) verify(alg, value string, body []byte) (string, error) {
+	kid, encoded, found := strings.Cut(value, ":")
+	if !found {
+		kid, encoded = "", value
+	}
+
+	size := 0
+	if alg == Ed25519Algorithm {
+		size = ed25519.SignatureSize
+	}
+
+	sig, err := decodeSignature(encoded, size)
+	if err != nil {
+		return "", err
+	}
+
+	keys, err := pk.keys(alg)
+	if err != nil {
+		return "", errors.Join(err, ErrInvalidSignature)
+	}
+
+	for _, k := range keys {
+		if k.ID != kid {
+			continue
+		}
+		if verifySignature(k.Key, sig, body) {
+			return k.ID, nil
+		}
+	}
+
+	return "", ErrInvalidSignature
+}
+
+// verifySignature verifies the signature of the body with the key.
+func verifySignature(key crypto.PublicKey, sig, body []byte) bool {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, body, sig)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(body)
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			return ecdsa.Verify(k, digest[:], r, s)
+		}
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	}
+	return false
+}
+
+// jwksFile is a JSON Web Key Set file that is loaded again whenever it changes.
+type jwksFile struct {
+	files *watchedFiles[[]PublicKey]
+}
+
+// newJWKSFile creates a jwksFile for the path.
+func newJWKSFile(path string) *jwksFile {
+	load := func() ([]PublicKey, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseJWKS(b)
+	}
+
+	return &jwksFile{
+		files: newWatchedFiles(load, path),
+	}
+}
+
+// load returns the keys in the file, reading the file again if it changed.
+func (f *jwksFile) load() ([]PublicKey, error) {
+	return f.files.get()
+}
+
+// jwk is the subset of a JSON Web Key needed for Ed25519 and P-256 keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses the supported keys in the JSON Web Key Set.
+func parseJWKS(b []byte) ([]PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]PublicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch {
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			key, err = parseEd25519JWK(k)
+		case k.Kty == "EC" && k.Crv == "P-256":
+			key, err = parseP256JWK(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s': %w", k.Kid, err)
+		}
+
+		keys = append(keys, PublicKey{ID: k.Kid, Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no supported keys found")
+	}
+
+	return keys, nil
+}
+
+func parseEd25519JWK(k jwk) (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid key size")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+func parseP256JWK(k jwk) (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid key size")
+	}
+
+	// Validate the point is on the curve.
+	point := append([]byte{4}, append(x, y...)...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
@@ -0,0 +1,346 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signEd25519(key ed25519.PrivateKey, body string) []byte {
+	return ed25519.Sign(key, []byte(body))
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, body string, raw bool) []byte {
+	digest := sha256.Sum256([]byte(body))
+	if !raw {
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		return sig
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+func TestAcceptPublicKeys(t *testing.T) {
+	const body = "body"
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherEdKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keys := []PublicKey{
+		{ID: "ed-1", Key: edPub},
+		{ID: "ec-1", Key: &ecKey.PublicKey},
+		{Key: edPub},
+	}
+
+	tests := []struct {
+		description string
+		header      string
+		alg         string
+		keyID       string
+		tokenErr    error
+		expectedErr error
+	}{
+		{
+			description: "ed25519 with a key id",
+			header:      "ed25519=ed-1:" + hex.EncodeToString(signEd25519(edKey, body)),
+			alg:         Ed25519Algorithm,
+			keyID:       "ed-1",
+		}, {
+			description: "ed25519 without a key id",
+			header:      "ed25519=" + hex.EncodeToString(signEd25519(edKey, body)),
+			alg:         Ed25519Algorithm,
+		}, {
+			description: "es256 raw signature",
+			header:      "ES256=ec-1:" + hex.EncodeToString(signES256(t, ecKey, body, true)),
+			alg:         ES256Algorithm,
+			keyID:       "ec-1",
+		}, {
+			description: "es256 asn.1 signature",
+			header:      "es256=ec-1:" + hex.EncodeToString(signES256(t, ecKey, body, false)),
+			alg:         ES256Algorithm,
+			keyID:       "ec-1",
+		}, {
+			description: "wrong key",
+			header:      "ed25519=ed-1:" + hex.EncodeToString(signEd25519(otherEdKey, body)),
+			alg:         Ed25519Algorithm,
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "unknown key id",
+			header:      "ed25519=ed-2:" + hex.EncodeToString(signEd25519(edKey, body)),
+			alg:         Ed25519Algorithm,
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "key id for a different algorithm",
+			header:      "es256=ed-1:" + hex.EncodeToString(signES256(t, ecKey, body, true)),
+			alg:         ES256Algorithm,
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "invalid encoding",
+			header:      "ed25519=ed-1:zz",
+			alg:         Ed25519Algorithm,
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "hmac not accepted",
+			header:      "sha256=abcd",
+			tokenErr:    ErrAlgorithmNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			l, err := New("http://example.com", &validWHR,
+				AcceptPublicKeys(keys...),
+			)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Header.Set(xmidtHeader, tc.header)
+
+			token, err := l.Tokenize(r)
+			if tc.tokenErr != nil {
+				assert.ErrorIs(err, tc.tokenErr)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.alg, token.Type())
+
+			match, err := l.AuthorizeMatch(r, token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(match)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.alg, match.Algorithm)
+			assert.Equal(tc.keyID, match.KeyID)
+			assert.Equal(-1, match.SecretIndex)
+		})
+	}
+}
+
+func TestAcceptPublicKeysOption(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []newTest{
+		{
+			description: "assert a key works",
+			r:           validWHR,
+			opts: []Option{
+				AcceptSHA256(),
+				AcceptPublicKeys(PublicKey{ID: "a", Key: edPub}),
+				AcceptPublicKeys(PublicKey{ID: "b", Key: edPub}),
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal([]string{"sha256", Ed25519Algorithm}, l.hashPreferences)
+				assert.Len(l.publicKeys.static, 2)
+			},
+		}, {
+			description: "assert no keys errors",
+			r:           validWHR,
+			opt:         AcceptPublicKeys(),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an unsupported curve errors",
+			r:           validWHR,
+			opt:         AcceptPublicKeys(PublicKey{Key: &p384.PublicKey}),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an unsupported key type errors",
+			r:           validWHR,
+			opt:         AcceptPublicKeys(PublicKey{Key: "key"}),
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+
+	opt := AcceptPublicKeys(PublicKey{ID: "a", Key: edPub}, PublicKey{ID: "b", Key: edPub})
+	assert.Equal(t, "AcceptPublicKeys(a, b)", opt.String())
+}
+
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	b, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return b
+}
+
+func TestAcceptJWKSFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const body = "body"
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	ecX := make([]byte, 32)
+	ecY := make([]byte, 32)
+	ecKey.X.FillBytes(ecX)
+	ecKey.Y.FillBytes(ecY)
+
+	edJWK := map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"kid": "ed-1",
+		"x":   base64.RawURLEncoding.EncodeToString(edPub),
+	}
+	ecJWK := map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": "ec-1",
+		"use": "sig",
+		"x":   base64.RawURLEncoding.EncodeToString(ecX),
+		"y":   base64.RawURLEncoding.EncodeToString(ecY),
+	}
+	rsaJWK := map[string]string{
+		"kty": "RSA",
+		"kid": "rsa-1",
+		"n":   "AQAB",
+		"e":   "AQAB",
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jwks.json")
+	now := time.Now()
+	writeFile(t, path, jwks(t, edJWK, rsaJWK), now)
+
+	opt := AcceptJWKSFile(path)
+	assert.Equal("AcceptJWKSFile("+path+")", opt.String())
+
+	l, err := New("http://example.com", &validWHR, opt)
+	require.NoError(err)
+	assert.Equal([]string{Ed25519Algorithm, ES256Algorithm}, l.hashPreferences)
+
+	authorize := func(header string) (*Match, error) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set(xmidtHeader, header)
+		token, err := l.Tokenize(r)
+		require.NoError(err)
+		return l.AuthorizeMatch(r, token)
+	}
+
+	edHeader := "ed25519=ed-1:" + hex.EncodeToString(signEd25519(edKey, body))
+	ecHeader := "es256=ec-1:" + hex.EncodeToString(signES256(t, ecKey, body, true))
+
+	match, err := authorize(edHeader)
+	require.NoError(err)
+	assert.Equal("ed-1", match.KeyID)
+
+	_, err = authorize(ecHeader)
+	assert.ErrorIs(err, ErrInvalidSignature)
+
+	// Rotate the keys by changing the file.
+	writeFile(t, path, jwks(t, ecJWK), now.Add(time.Minute))
+
+	match, err = authorize(ecHeader)
+	require.NoError(err)
+	assert.Equal("ec-1", match.KeyID)
+
+	_, err = authorize(edHeader)
+	assert.ErrorIs(err, ErrInvalidSignature)
+}
+
+func TestAcceptJWKSFileOption(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	unsupported := filepath.Join(dir, "unsupported.json")
+	writeFile(t, unsupported, jwks(t, map[string]string{"kty": "RSA", "n": "AQAB", "e": "AQAB"}), now)
+
+	encryption := filepath.Join(dir, "encryption.json")
+	writeFile(t, encryption, jwks(t, map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"use": "enc",
+		"x":   base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+	}), now)
+
+	badPoint := filepath.Join(dir, "bad_point.json")
+	writeFile(t, badPoint, jwks(t, map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+		"y":   base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+	}), now)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	writeFile(t, invalid, []byte("{"), now)
+
+	valid := filepath.Join(dir, "valid.json")
+	writeFile(t, valid, jwks(t, map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"x":   base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+	}), now)
+
+	tests := []newTest{
+		{
+			description: "assert a missing file errors",
+			r:           validWHR,
+			opt:         AcceptJWKSFile(filepath.Join(dir, "missing.json")),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid file errors",
+			r:           validWHR,
+			opt:         AcceptJWKSFile(invalid),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert a file without supported keys errors",
+			r:           validWHR,
+			opt:         AcceptJWKSFile(unsupported),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert encryption keys are ignored",
+			r:           validWHR,
+			opt:         AcceptJWKSFile(encryption),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert a point not on the curve errors",
+			r:           validWHR,
+			opt:         AcceptJWKSFile(badPoint),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert multiple files errors",
+			r:           validWHR,
+			opts: []Option{
+				AcceptJWKSFile(valid),
+				AcceptJWKSFile(valid),
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+}
@@ -6,14 +6,12 @@ package listener
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
 	"time"
@@ -28,28 +26,52 @@ const (
 	xmidtHeader = "Xmidt-Signature"
 )
 
+// requestIDHeaders are the headers checked, in order, for an identifier of the
+// callback request to include in events.
+var requestIDHeaders = []string{
+	"X-Request-Id",
+	"X-Webpa-Transaction-Id",
+}
+
 // Listener provides a way to register a webhook and validate the callbacks.
 // It can be configured to register the webhook at a given interval, as well as
 // it can also be configured to accept multiple secrets and hash algorithms.
 type Listener struct {
 	m                     sync.RWMutex
 	wg                    sync.WaitGroup
-	registration          *webhook.Registration
+	name                  string
+	payload               Payload
 	webhookURL            string
+	endpoints             *endpoints
 	registrationOpts      []webhook.Option
 	interval              time.Duration
 	client                *http.Client
 	shutdown              context.CancelFunc
 	update                chan struct{}
+	failure               error
 	reqDecorators         []Decorator
 	registrationListeners eventor.Eventor[event.RegistrationListener]
 	authorizeListeners    eventor.Eventor[event.AuthorizeListener]
 	tokenizeListeners     eventor.Eventor[event.TokenizeListener]
+	async                 bool
+	eventBufferSize       int
+	overflowPolicy        OverflowPolicy
+	events                *dispatcher
 	opts                  []Option
 	body                  []byte
 	acceptedSecrets       []string
+	secretLabels          []string
+	fingerprints          bool
 	hashPreferences       []string
+	certPolicy            *CertificatePolicy
+	publicKeys            publicKeys
+	allowedSources        []netip.Prefix
+	trustedProxies        []netip.Prefix
+	verifier              Verifier
+	tokenizer             TokenizerFunc
 	hashes                map[string]func() hash.Hash
+	macs                  map[string][]*keyedMAC
+	encoding              *contentEncoding
 }
 
 // Option is an interface that is used to configure the webhook listener.
@@ -71,24 +93,46 @@ func New(url string, r *webhook.Registration, opts ...Option) (*Listener, error)
 		return nil, fmt.Errorf("%w: registration is required", ErrInput)
 	}
 
+	return newListener(url, &registrationPayload{r: r}, opts)
+}
+
+// NewWithPayload creates a new webhook listener with the given registration
+// payload and options.  This allows registration schemas other than
+// webhook.Registration to be used.  The WebhookOpts() option has no effect on
+// the validation of the payload.
+func NewWithPayload(url string, p Payload, opts ...Option) (*Listener, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: registration payload is required", ErrInput)
+	}
+
+	return newListener(url, p, opts)
+}
+
+func newListener(url string, p Payload, opts []Option) (*Listener, error) {
 	url = strings.TrimSpace(url)
 	if url == "" {
 		return nil, fmt.Errorf("%w: webhook url is required", ErrInput)
 	}
 
 	l := Listener{
-		registration:     r,
+		payload:          p,
 		webhookURL:       url,
+		endpoints:        newEndpoints(url),
 		registrationOpts: make([]webhook.Option, 0),
 		client:           http.DefaultClient,
 		reqDecorators:    make([]Decorator, 0),
 		update:           make(chan struct{}, 1),
 		acceptedSecrets:  make([]string, 0),
+		secretLabels:     make([]string, 0),
 		hashPreferences:  make([]string, 0),
 		hashes:           make(map[string]func() hash.Hash, 0),
 		opts:             opts,
 	}
 
+	if err := checkClientOptions(opts); err != nil {
+		return nil, err
+	}
+
 	for _, opt := range opts {
 		if opt == nil {
 			continue
@@ -99,28 +143,168 @@ func New(url string, r *webhook.Registration, opts ...Option) (*Listener, error)
 		}
 	}
 
-	vOpts := []webhook.Option{
-		webhook.ValidateRegistrationDuration(0),
+	if l.verifier == nil {
+		l.verifier = &signatureVerifier{l: &l}
 	}
 
-	if l.interval != 0 {
-		vOpts = append(vOpts, webhook.NoUntil())
+	l.macs = buildMACs(l.hashes, l.acceptedSecrets)
+
+	if rp, ok := l.payload.(*registrationPayload); ok {
+		rp.opts = l.registrationOpts
 	}
-	vOpts = append(vOpts, l.registrationOpts...)
 
-	err := l.registration.Validate(vOpts...)
+	err := l.validate(l.payload, l.interval)
 	if err != nil {
-		return nil, errors.Join(err, fmt.Errorf("%w: invalid registration", ErrInput))
+		return nil, err
 	}
 
-	err = l.use(l.registration.Config.Secret)
+	err = l.use(l.payload.Secret())
 	if err != nil {
 		return nil, err
 	}
 
+	if l.async {
+		l.events = newDispatcher(l.eventBufferSize, l.overflowPolicy)
+	}
+
 	return &l, nil
 }
 
+// checkClientOptions returns an error if both the ClientCertificates() and
+// HTTPClient() options are provided, since both replace the http client.
+func checkClientOptions(opts []Option) error {
+	var certs, client bool
+	for _, opt := range opts {
+		switch opt.(type) {
+		case *clientCertificatesOption:
+			certs = true
+		case *httpClientOption:
+			client = true
+		}
+	}
+
+	if certs && client {
+		return fmt.Errorf("%w: ClientCertificates cannot be used with HTTPClient", ErrInput)
+	}
+	return nil
+}
+
+// validate validates the registration payload using the interval.
+func (l *Listener) validate(p Payload, interval time.Duration) error {
+	err := p.Validate(interval)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("%w: invalid registration", ErrInput))
+	}
+
+	return nil
+}
+
+// Update atomically replaces the registration of the webhook listener and
+// applies the options provided.  The registration is validated using the same
+// webhook.Options provided to New().  If the registration does not include a
+// secret, the current secret is kept.  If the listener is running, the webhook
+// is registered again immediately, even if the registrations stopped because
+// of a permanent failure.
+//
+// Only the Interval(), Once() and HTTPClient() options may be provided.  A
+// running listener may not be changed to only register once.
+func (l *Listener) Update(r *webhook.Registration, opts ...Option) error {
+	if r == nil {
+		return fmt.Errorf("%w: registration is required", ErrInput)
+	}
+
+	return l.UpdatePayload(&registrationPayload{r: r, opts: l.registrationOpts}, opts...)
+}
+
+// UpdatePayload atomically replaces the registration payload of the webhook
+// listener and applies the options provided.  See Update() for details.
+func (l *Listener) UpdatePayload(p Payload, opts ...Option) error {
+	if p == nil {
+		return fmt.Errorf("%w: registration payload is required", ErrInput)
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	// Apply the options to a scratch copy so nothing changes unless
+	// everything is valid.
+	next := Listener{
+		interval: l.interval,
+		client:   l.client,
+	}
+	for _, opt := range opts {
+		switch opt.(type) {
+		case nil:
+			continue
+		case *intervalOption, *httpClientOption:
+		default:
+			return fmt.Errorf("%w: %s cannot be used with Update", ErrInput, opt.String())
+		}
+
+		if err := opt.apply(&next); err != nil {
+			return err
+		}
+	}
+
+	if l.shutdown != nil && next.interval == 0 {
+		return fmt.Errorf("%w: a running listener cannot be changed to Once", ErrInput)
+	}
+
+	if err := l.validate(p, next.interval); err != nil {
+		return err
+	}
+
+	secret := p.Secret()
+	if secret == "" {
+		secret = l.payload.Secret()
+	}
+
+	prev := l.payload
+	l.payload = p
+	if err := l.use(secret); err != nil {
+		l.payload = prev
+		return err
+	}
+
+	l.interval = next.interval
+	l.client = next.client
+	for _, opt := range opts {
+		if opt != nil {
+			l.opts = supersede(l.opts, opt)
+		}
+	}
+
+	return nil
+}
+
+// supersede returns the options with any option for the same setting as opt
+// replaced by opt.
+func supersede(opts []Option, opt Option) []Option {
+	kept := make([]Option, 0, len(opts)+1)
+	for _, o := range opts {
+		if !sameSetting(o, opt) {
+			kept = append(kept, o)
+		}
+	}
+	return append(kept, opt)
+}
+
+// sameSetting returns true if both options configure the same setting of the
+// Listener.
+func sameSetting(a, b Option) bool {
+	switch a.(type) {
+	case *intervalOption:
+		_, ok := b.(*intervalOption)
+		return ok
+	case *httpClientOption, *clientCertificatesOption:
+		switch b.(type) {
+		case *httpClientOption, *clientCertificatesOption:
+			return true
+		}
+	}
+	return false
+}
+
 // AddRegistrationEventListener adds an event listener to the webhook listener.
 // The listener will be called for each event that occurs.  The returned
 // function can be called to remove the listener.
@@ -142,27 +326,44 @@ func (l *Listener) AddAuthorizeEventListener(listener event.AuthorizeListener) C
 	return CancelEventListenerFunc(l.authorizeListeners.Add(listener))
 }
 
+// DroppedEvents returns the number of events that were discarded because the
+// asynchronous event buffer was full.  This is always 0 unless AsyncEvents is
+// used with a dropping OverflowPolicy.
+func (l *Listener) DroppedEvents() uint64 {
+	return l.events.droppedCount()
+}
+
 // dispatch dispatches the event to the listeners and returns the error that
-// should be returned by the caller.
+// should be returned by the caller.  If AsyncEvents is used the listeners are
+// called from a background goroutine.
 func dispatch[T event.Authorize | event.Registration | event.Tokenize](l *Listener, evnt T) error {
 	var err error
+	var fn func()
 	switch evnt := any(evnt).(type) {
 	case event.Registration:
-		l.registrationListeners.Visit(func(listener event.RegistrationListener) {
-			listener.OnRegistrationEvent(evnt)
-		})
+		fn = func() {
+			l.registrationListeners.Visit(func(listener event.RegistrationListener) {
+				listener.OnRegistrationEvent(evnt)
+			})
+		}
 		err = evnt.Err
 	case event.Tokenize:
-		l.tokenizeListeners.Visit(func(listener event.TokenizeListener) {
-			listener.OnTokenizeEvent(evnt)
-		})
+		fn = func() {
+			l.tokenizeListeners.Visit(func(listener event.TokenizeListener) {
+				listener.OnTokenizeEvent(evnt)
+			})
+		}
 		err = evnt.Err
 	case event.Authorize:
-		l.authorizeListeners.Visit(func(listener event.AuthorizeListener) {
-			listener.OnAuthorizeEvent(evnt)
-		})
+		fn = func() {
+			l.authorizeListeners.Visit(func(listener event.AuthorizeListener) {
+				listener.OnAuthorizeEvent(evnt)
+			})
+		}
 		err = evnt.Err
 	}
+
+	l.events.send(fn)
 	return err
 }
 
@@ -172,33 +373,45 @@ func dispatch[T event.Authorize | event.Registration | event.Tokenize](l *Listen
 // already running, the secret will be updated immediately.
 // If the secret is not provided, the current secret will be used.  Only the
 // first secret will be used if multiple secrets are provided.
+//
+// If the registrations stopped because of a permanent failure, they are
+// restarted.  See Err() for details.
 func (l *Listener) Register(ctx context.Context, secret ...string) error {
 	l.m.Lock()
-	defer l.m.Unlock()
 
 	if len(secret) != 0 {
 		if err := l.use(secret[0]); err != nil {
+			l.m.Unlock()
 			return err
 		}
 	}
 
 	if l.shutdown != nil {
+		if l.failure != nil || l.endpoints.stopped() {
+			l.retry()
+		}
+		l.m.Unlock()
 		return nil
 	}
 
 	if l.interval == 0 {
-		_, err := l.register(ctx, true, time.Time{})
-		return err
+		// Release the lock before registering, since the event listeners
+		// may call back into the listener.
+		l.m.Unlock()
+		l.endpoints.restart()
+		return l.register(ctx, time.Time{}).err
 	}
 
 	ctx, l.shutdown = context.WithCancel(ctx)
 	go l.run(ctx)
 
+	l.m.Unlock()
 	return nil
 }
 
 // Stop stops the webhook listener.  If the listener is not running, this is a
-// no-op.
+// no-op.  If AsyncEvents is used, Stop waits for the buffered events to be
+// delivered and any later events are delivered synchronously.
 func (l *Listener) Stop() {
 	l.m.Lock()
 	shutdown := l.shutdown
@@ -208,24 +421,32 @@ func (l *Listener) Stop() {
 		shutdown()
 	}
 	l.wg.Wait()
+	l.events.stop()
 }
 
 func (l *Listener) use(secret string) error {
-	l.registration.Config.Secret = secret
+	l.payload.SetSecret(secret)
 
 	var err error
-	l.body, err = json.Marshal(&l.registration)
+	l.body, err = l.payload.Marshal()
 	if err != nil {
 		return errors.Join(err, fmt.Errorf("%w: unable to marshal the registration", ErrInput))
 	}
 
-	// Update the hash functions without blocking.
+	l.retry()
+
+	return nil
+}
+
+// retry signals the run loop to register again immediately without blocking.
+// Every endpoint is registered again, not only the ones that failed.
+func (l *Listener) retry() {
+	l.endpoints.restart()
+
 	select {
 	case l.update <- struct{}{}:
 	default:
 	}
-
-	return nil
 }
 
 // Accept defines the entire list of secrets to accept for the webhook callbacks.
@@ -237,6 +458,24 @@ func (l *Listener) Accept(secrets []string) {
 
 	l.acceptedSecrets = make([]string, len(secrets))
 	copy(l.acceptedSecrets, secrets)
+	l.secretLabels = make([]string, len(secrets))
+	l.macs = buildMACs(l.hashes, l.acceptedSecrets)
+}
+
+// AcceptSecrets defines the entire list of labeled secrets to accept for the
+// webhook callbacks.  If any of the secrets match the secret in the token, the
+// request will be authorized and the label of the matching secret is reported.
+func (l *Listener) AcceptSecrets(secrets []Secret) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.acceptedSecrets = make([]string, 0, len(secrets))
+	l.secretLabels = make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		l.acceptedSecrets = append(l.acceptedSecrets, secret.Value)
+		l.secretLabels = append(l.secretLabels, secret.Label)
+	}
+	l.macs = buildMACs(l.hashes, l.acceptedSecrets)
 }
 
 // run is the main loop for the webhook listener.  It will register the webhook
@@ -246,15 +485,45 @@ func (l *Listener) run(ctx context.Context) {
 	l.wg.Add(1)
 	defer l.wg.Done()
 
-	ticker := time.NewTicker(l.interval)
+	ticker := time.NewTicker(l.getInterval())
 	defer ticker.Stop()
 
+	backoff := unauthorizedBackoff
 	for {
-		exp, err := l.register(ctx, false, presentExpiration)
-		if err == nil {
-			presentExpiration = exp
-			ticker.Reset(l.interval)
-		} else {
+		a := l.register(ctx, presentExpiration)
+		if !errors.Is(a.err, ErrRegistrationUnauthorized) {
+			backoff = unauthorizedBackoff
+		}
+
+		switch {
+		case a.err == nil:
+			presentExpiration = a.until
+			ticker.Reset(l.renewIn(a.until))
+		case permanent(a.err):
+			// Retrying will not help, so wait until the registration is
+			// changed or explicitly registered again.
+			ticker.Stop()
+			l.fail(a.err, presentExpiration)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.update:
+			}
+
+			l.m.Lock()
+			l.failure = nil
+			l.m.Unlock()
+			continue
+		case errors.Is(a.err, ErrRegistrationUnauthorized):
+			// The credentials may be rotated, so keep trying, but back off
+			// and never wait longer than the registration interval.
+			ticker.Reset(min(max(backoff, a.retryAfter), l.getInterval()))
+			backoff = min(2*backoff, maxUnauthorizedBackoff)
+		case a.retryAfter > 0:
+			// Never wait longer than the registration interval.
+			ticker.Reset(min(a.retryAfter, l.getInterval()))
+		default:
 			// TODO add better retry logic
 			ticker.Reset(time.Second)
 		}
@@ -269,9 +538,33 @@ func (l *Listener) run(ctx context.Context) {
 	}
 }
 
+// renewIn returns how long to wait before renewing the registrations that
+// expire at until.  The registrations are renewed early if the webhook server
+// reports an expiration that is sooner than the interval.
+func (l *Listener) renewIn(until time.Time) time.Duration {
+	interval := l.getInterval()
+	if l.endpoints.fanOut {
+		return l.endpoints.renewIn(interval)
+	}
+
+	now := time.Now()
+	return max(renewAt(now, until, interval).Sub(now), min(interval, minRenewal))
+}
+
+// getInterval returns the interval between registrations.
+func (l *Listener) getInterval() time.Duration {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	return l.interval
+}
+
 // String returns a string representation of the webhook listener and the options
 // used to configure it.
 func (l *Listener) String() string {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
 	buf := strings.Builder{}
 
 	buf.WriteString("Listener(")
@@ -291,169 +584,370 @@ func (l *Listener) String() string {
 	return buf.String()
 }
 
+// permanent returns true if the registration error cannot be resolved by
+// retrying the same registration.  Unauthorized registrations are not
+// permanent, since the credentials may be rotated.
+func permanent(err error) bool {
+	return errors.Is(err, ErrRegistrationRejected)
+}
+
+const (
+	// unauthorizedBackoff is how long to wait before retrying a registration
+	// whose credentials were rejected.  The wait doubles with each consecutive
+	// rejection up to maxUnauthorizedBackoff.
+	unauthorizedBackoff    = time.Second
+	maxUnauthorizedBackoff = 5 * time.Minute
+)
+
+// fail records the permanent failure of the registrations and dispatches the
+// terminal event.
+func (l *Listener) fail(err error, presentExpiration time.Time) {
+	l.m.Lock()
+	l.failure = err
+	l.m.Unlock()
+
+	_ = dispatch(l, event.Registration{
+		Name:     l.name,
+		At:       time.Now(),
+		Until:    presentExpiration,
+		Terminal: true,
+		Err:      err,
+	})
+}
+
+// attempt is the outcome of a registration attempt.
+type attempt struct {
+	// until is when the registration expires if it succeeded.
+	until time.Time
+
+	// retryAfter is the delay requested by the webhook server before the
+	// next attempt, or 0 if there was no request.
+	retryAfter time.Duration
+
+	// failover is true if the endpoint appears to be unavailable and the
+	// next endpoint should be tried.
+	failover bool
+
+	err error
+}
+
 // register registers the webhook listener.  The newest secret will be used for
-// the registration.  The locked argument determines if a mutex is already held
-// by the caller to prevent deadlock.
-func (l *Listener) register(ctx context.Context, locked bool, presentExpiration time.Time) (time.Time, error) {
+// the registration.  The caller must not hold the mutex.
+//
+// The endpoints are tried in order until one of them does not fail in a way
+// that indicates the endpoint is unavailable.  An event is dispatched for
+// each attempt.
+func (l *Listener) register(ctx context.Context, presentExpiration time.Time) attempt {
 	// Keep the lock block as small as possible.  Copy out the values that are
-	// needed and release the lock.
-	if !locked {
-		l.m.RLock()
+	// needed and release the lock, so no lock is held while the events are
+	// dispatched.
+	l.m.RLock()
+	body := l.body
+	client := l.client
+	payload := l.payload
+	interval := l.interval
+	l.m.RUnlock()
+
+	if l.endpoints.fanOut {
+		return l.registerAll(ctx, body, client, payload, presentExpiration, interval)
 	}
 
-	address := l.webhookURL
-	body := l.body
+	var a attempt
+	for _, address := range l.endpoints.order() {
+		a = l.registerWith(ctx, address, body, client, payload, presentExpiration)
+		if !a.failover {
+			break
+		}
+	}
+
+	return a
+}
 
-	if !locked {
-		l.m.RUnlock()
+// registerAll registers with every endpoint that is due concurrently.  Only
+// the endpoints that failed or need renewing are registered, so a failure does
+// not cause the other endpoints to be registered again.  The registration only
+// succeeds if all the endpoints accept it, in which case the earliest
+// expiration is returned.  The longest delay requested by any endpoint is
+// returned.  An event is dispatched for each endpoint.
+//
+// When registering at an interval, an endpoint that fails permanently is
+// stopped and a terminal event is dispatched for it, while the other endpoints
+// continue to be registered.  The failure is only permanent once every
+// endpoint is stopped.
+func (l *Listener) registerAll(ctx context.Context, body []byte, client *http.Client, payload Payload, presentExpiration time.Time, interval time.Duration) attempt {
+	urls := l.endpoints.due(interval)
+
+	attempts := make([]attempt, len(urls))
+
+	var wg sync.WaitGroup
+	for i, address := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempts[i] = l.registerWith(ctx, address, body, client, payload, presentExpiration)
+		}()
+	}
+	wg.Wait()
+
+	var all attempt
+	errs := make([]error, 0, len(attempts))
+	var stopped []int
+	for i, a := range attempts {
+		l.endpoints.attempted(urls[i], a.err)
+		all.retryAfter = max(all.retryAfter, a.retryAfter)
+		if interval > 0 && permanent(a.err) {
+			stopped = append(stopped, i)
+			continue
+		}
+		errs = append(errs, a.err)
 	}
 
-	evnt := event.Registration{
-		Until: presentExpiration,
+	if err := l.endpoints.failure(); err != nil {
+		all.err = err
+		return all
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
-	if err != nil {
-		evnt.Err = errors.Join(err, ErrNewRequestFailed, ErrRegistrationNotAttempted)
-		return time.Time{}, dispatch(l, evnt)
+	for _, i := range stopped {
+		_ = dispatch(l, event.Registration{
+			Name:     l.name,
+			Endpoint: urls[i],
+			At:       time.Now(),
+			Until:    presentExpiration,
+			Terminal: true,
+			Err:      attempts[i].err,
+		})
 	}
 
-	for _, decorator := range l.reqDecorators {
-		err := decorator.Decorate(req)
+	if all.err = errors.Join(errs...); all.err == nil {
+		all.until = l.endpoints.expiration()
+	}
+	return all
+}
+
+// registerWith performs a single registration attempt against the address.
+func (l *Listener) registerWith(ctx context.Context, address string, body []byte, client *http.Client, payload Payload, presentExpiration time.Time) attempt {
+	evnt := event.Registration{
+		Name:     l.name,
+		Endpoint: address,
+		Until:    presentExpiration,
+	}
+
+	var resp *http.Response
+	for retry := true; ; retry = false {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
 		if err != nil {
-			evnt.Err = errors.Join(err, ErrDecoratorFailed, ErrRegistrationNotAttempted)
-			return time.Time{}, dispatch(l, evnt)
+			l.endpoints.report(address, false, time.Time{})
+			evnt.Err = errors.Join(err, ErrNewRequestFailed, ErrRegistrationNotAttempted)
+			return attempt{failover: true, err: dispatch(l, evnt)}
 		}
-	}
 
-	req.Header.Set("Content-Type", "application/json")
+		for _, decorator := range l.reqDecorators {
+			err := decorator.Decorate(req)
+			if err != nil {
+				evnt.Err = errors.Join(err, ErrDecoratorFailed, ErrRegistrationNotAttempted)
+				return attempt{err: dispatch(l, evnt)}
+			}
+		}
 
-	evnt.At = time.Now()
-	resp, err := l.client.Do(req)
-	evnt.Duration = time.Since(evnt.At)
+		req.Header.Set("Content-Type", "application/json")
 
-	if err != nil {
-		evnt.Err = errors.Join(err, ErrRegistrationFailed)
-		return time.Time{}, dispatch(l, evnt)
+		evnt.At = time.Now()
+		resp, err = client.Do(req)
+		evnt.Duration = time.Since(evnt.At)
+
+		if err != nil {
+			l.endpoints.report(address, false, time.Time{})
+			evnt.Err = errors.Join(err, ErrRegistrationFailed)
+			return attempt{failover: ctx.Err() == nil, err: dispatch(l, evnt)}
+		}
+
+		// The credentials may have been revoked or expired early, so refresh
+		// them and try once more.
+		if retry && resp.StatusCode == http.StatusUnauthorized && l.refreshCredentials() {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+			resp.Body.Close()
+			continue
+		}
+		break
 	}
 	defer resp.Body.Close()
 
 	evnt.StatusCode = resp.StatusCode
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	evnt.Err = classify(resp.StatusCode)
+	if evnt.Err == nil {
+		rr := parseResponse(respBody)
+		evnt.ID = rr.id
+		evnt.Warnings = rr.warnings
+		evnt.Until = rr.until
+		if evnt.Until.IsZero() {
+			evnt.Until = payload.Expiration(evnt.At)
+		}
 
-	if resp.StatusCode == http.StatusOK {
-		evnt.Until = evnt.At.Add(time.Duration(l.registration.Duration))
-		return evnt.Until, dispatch(l, evnt)
+		l.endpoints.report(address, true, evnt.Until)
+		return attempt{until: evnt.Until, err: dispatch(l, evnt)}
 	}
 
-	evnt.Body, _ = io.ReadAll(resp.Body)
-	evnt.Err = ErrRegistrationFailed
+	// An unavailable server indicates a problem with the endpoint, not the
+	// registration, so another endpoint may succeed.
+	unavailable := errors.Is(evnt.Err, ErrRegistrationUnavailable)
+	l.endpoints.report(address, !unavailable, time.Time{})
 
-	return time.Time{}, dispatch(l, evnt)
-}
-
-// Tokenize parses the token from the request header.  If the token is not found
-// or is invalid, an error is returned.
-func (l *Listener) Tokenize(r *http.Request) (*token, error) {
-	evnt := event.Tokenize{
-		Header: xmidtHeader,
+	evnt.RetryAfter = retryAfter(resp, time.Now())
+	if evnt.RetryAfter > 0 {
+		l.endpoints.backoff(address, evnt.RetryAfter)
 	}
 
-	headers := r.Header.Values(xmidtHeader)
-	if len(headers) == 0 {
-		headers = r.Header.Values(webpaHeader)
-		evnt.Header = webpaHeader
-	}
+	evnt.Body = respBody
 
-	if len(headers) == 0 {
-		evnt.Header = ""
+	return attempt{
+		retryAfter: evnt.RetryAfter,
+		failover:   unavailable,
+		err:        dispatch(l, evnt),
 	}
+}
 
-	choices := map[string]string{
-		"none": "",
-	}
-	list := make([]string, 0, len(headers))
-	list = append(list, "none")
-
-	for _, header := range headers {
-		header = strings.TrimSpace(header)
-		if header == "" {
-			continue
-		}
-		parts := strings.Split(header, "=")
-		if len(parts) != 2 {
-			evnt.Err = errors.Join(ErrInvalidTokenHeader, ErrInvalidHeaderFormat)
-			return nil, dispatch(l, evnt)
-		}
-
-		alg := strings.ToLower(strings.TrimSpace(parts[0]))
-		val := strings.TrimSpace(parts[1])
-		if alg == "" || val == "" {
-			evnt.Err = errors.Join(ErrInvalidTokenHeader, ErrInvalidHeaderFormat)
-			return nil, dispatch(l, evnt)
+// refreshCredentials refreshes the credentials of the request decorators that
+// support it.  It returns true if any credentials were refreshed.
+func (l *Listener) refreshCredentials() bool {
+	var refreshed bool
+	for _, decorator := range l.reqDecorators {
+		if r, ok := decorator.(Refresher); ok {
+			r.Refresh()
+			refreshed = true
 		}
+	}
+	return refreshed
+}
 
-		choices[alg] = val
-		list = append(list, alg)
+// Tokenize parses the token from the request using the tokenizer provided by
+// WithTokenizer() or the Verifier.  If the token is not found or is invalid,
+// an error is returned.
+func (l *Listener) Tokenize(r *http.Request) (Token, error) {
+	info := describe(r)
+	evnt := event.Tokenize{
+		At:            time.Now(),
+		RemoteAddr:    info.remoteAddr,
+		Path:          info.path,
+		ContentLength: info.contentLength,
+		ContentType:   info.contentType,
+		RequestID:     info.requestID,
 	}
 
-	evnt.Algorithms = list
-	best, err := l.best(list)
+	t, err := l.tokenize(r, &evnt)
+	evnt.Duration = time.Since(evnt.At)
 	if err != nil {
-		evnt.Err = errors.Join(ErrInvalidTokenHeader, ErrAlgorithmNotFound)
+		evnt.Err = err
 		return nil, dispatch(l, evnt)
 	}
 
-	evnt.Algorithm = best
 	dispatch(l, evnt)
-	return newToken(best, choices[best]), nil
+	return t, nil
 }
 
 // Authorize validates that the request body matches the hash and secret provided
 // in the token.
 func (l *Listener) Authorize(r *http.Request, t Token) error {
-	var evnt event.Authorize
+	_, err := l.AuthorizeMatch(r, t)
+	return err
+}
 
-	if t == nil {
-		evnt.Err = ErrNoToken
-		return dispatch(l, evnt)
+// AuthorizeMatch validates that the request body matches the hash and secret
+// provided in the token, the same as Authorize.  On success the accepted secret
+// or client certificate that matched is described by the returned Match.  If
+// AcceptContentEncoding() is used, compressed bodies are replaced by the
+// decompressed body on success.
+func (l *Listener) AuthorizeMatch(r *http.Request, t Token) (*Match, error) {
+	info := describe(r)
+	evnt := event.Authorize{
+		At:            time.Now(),
+		SecretIndex:   -1,
+		RemoteAddr:    info.remoteAddr,
+		Path:          info.path,
+		ContentLength: info.contentLength,
+		ContentType:   info.contentType,
+		RequestID:     info.requestID,
 	}
 
-	secret, err := hex.DecodeString(t.Principal())
-	if err != nil {
-		evnt.Err = errors.Join(err, ErrInvalidSignature)
-		return dispatch(l, evnt)
+	// Reject unexpected sources before doing any more work.
+	if len(l.allowedSources) > 0 {
+		var err error
+		evnt.ClientIP, err = l.checkSource(r)
+		if err != nil {
+			evnt.Duration = time.Since(evnt.At)
+			evnt.Err = err
+			return nil, dispatch(l, evnt)
+		}
+	}
+
+	if t == nil {
+		evnt.Duration = time.Since(evnt.At)
+		evnt.Err = ErrNoToken
+		return nil, dispatch(l, evnt)
 	}
 
 	var msg []byte
 	if r.Body != nil {
+		var err error
 		msg, err = io.ReadAll(r.Body)
 		r.Body.Close()
 		if err != nil {
+			evnt.Duration = time.Since(evnt.At)
 			evnt.Err = errors.Join(err, ErrUnableToReadBody)
-			return dispatch(l, evnt)
+			return nil, dispatch(l, evnt)
 		}
 
 		// Reset the body so it can be read again later.
 		r.Body = io.NopCloser(bytes.NewReader(msg))
 	}
 
-	evnt.Algorithm = t.Type()
-	hashes, err := l.getHashes(evnt.Algorithm)
-	if err != nil {
-		evnt.Err = err
-		return dispatch(l, evnt)
+	// Compressed bodies are only decoded if enabled, and only before the
+	// signature is verified if the signature covers the decoded body.
+	var enc string
+	var decoded []byte
+	signed := msg
+	if l.encoding != nil && len(msg) > 0 {
+		var err error
+		enc = encodingOf(r)
+		if enc != "" && l.encoding.signed == SignedDecoded {
+			decoded, err = l.encoding.decode(enc, msg)
+			signed = decoded
+		} else {
+			err = checkEncoding(enc)
+		}
+		if err != nil {
+			evnt.Duration = time.Since(evnt.At)
+			evnt.Err = err
+			return nil, dispatch(l, evnt)
+		}
 	}
 
-	for _, h := range hashes {
-		h.Write(msg)
-		if hmac.Equal(h.Sum(nil), secret) {
-			dispatch(l, evnt)
-			return nil
+	err := l.verifier.Verify(r, t, signed, &evnt)
+	if err == nil && enc != "" {
+		if decoded == nil {
+			decoded, err = l.encoding.decode(enc, msg)
+		}
+		if err == nil {
+			replaceBody(r, decoded)
 		}
 	}
 
-	evnt.Err = ErrInvalidSignature
-	return dispatch(l, evnt)
+	evnt.Duration = time.Since(evnt.At)
+	if err != nil {
+		evnt.Err = err
+		return nil, dispatch(l, evnt)
+	}
+
+	dispatch(l, evnt)
+	return &Match{
+		Algorithm:         evnt.Algorithm,
+		SecretIndex:       evnt.SecretIndex,
+		SecretLabel:       evnt.SecretLabel,
+		SecretFingerprint: evnt.SecretFingerprint,
+		ClientCertificate: evnt.ClientCertificate,
+		KeyID:             evnt.KeyID,
+	}, nil
 }
 
 // best returns the best secret to use for the given choices.  If none of the
@@ -473,19 +967,66 @@ func (l *Listener) best(choices []string) (string, error) {
 	return "", ErrNotAcceptedHash
 }
 
-// hashes returns a slice of hashes of the active secrets.
-func (l *Listener) getHashes(which string) ([]hash.Hash, error) {
+// hashSize returns the size of the signatures created by the hash, or 0 if
+// the hash is not accepted or has no hash function, such as "none".
+func (l *Listener) hashSize(which string) int {
 	l.m.RLock()
 	defer l.m.RUnlock()
 
 	h, found := l.hashes[which]
+	if !found || h == nil {
+		return 0
+	}
+	return h().Size()
+}
+
+// getMACs returns the keyed HMACs of the active secrets as well as the
+// secrets and their labels in the same order.
+func (l *Listener) getMACs(which string) ([]*keyedMAC, []string, []string, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	macs, found := l.macs[which]
 	if !found {
-		return nil, ErrNotAcceptedHash
+		return nil, nil, nil, ErrNotAcceptedHash
+	}
+
+	// The MACs, accepted secrets and labels are replaced together and never
+	// modified in place, so the slices are safe to use after the lock is
+	// released.
+	return macs, l.acceptedSecrets, l.secretLabels, nil
+}
+
+// requestInfo holds the details of a callback request that are included in
+// events.
+type requestInfo struct {
+	remoteAddr    string
+	path          string
+	contentLength int64
+	contentType   string
+	requestID     string
+}
+
+// describe collects the details of the callback request to include in events.
+func describe(r *http.Request) requestInfo {
+	var info requestInfo
+	if r == nil {
+		return info
 	}
 
-	hashes := make([]hash.Hash, 0, len(l.acceptedSecrets))
-	for _, secret := range l.acceptedSecrets {
-		hashes = append(hashes, hmac.New(h, []byte(secret)))
+	info.remoteAddr = r.RemoteAddr
+	info.contentLength = r.ContentLength
+	if r.URL != nil {
+		info.path = r.URL.Path
 	}
-	return hashes, nil
+
+	info.contentType = r.Header.Get("Content-Type")
+	for _, header := range requestIDHeaders {
+		if id := strings.TrimSpace(r.Header.Get(header)); id != "" {
+			info.requestID = id
+			break
+		}
+	}
+
+	return info
 }
@@ -6,6 +6,7 @@ package listener
 import (
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -43,6 +44,12 @@ func vadorAcceptedSecrets(ok ...string) vador {
 	}
 }
 
+// registrationOf returns the webhook.Registration the listener was created
+// with.
+func registrationOf(l *Listener) *webhook.Registration {
+	return l.payload.(*registrationPayload).r
+}
+
 var validWHR = webhook.Registration{
 	Duration: webhook.CustomDuration(5 * time.Minute),
 }
@@ -146,9 +153,9 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 			opt: AcceptSHA1(),
-			expected: token{
-				alg:       "sha1",
-				principal: "12345",
+			expected: Signature{
+				Algorithm: "sha1",
+				Value:     "12345",
 			},
 			event: &event.Tokenize{
 				Header:     webpaHeader,
@@ -163,9 +170,9 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 			opt: AcceptSHA1(),
-			expected: token{
-				alg:       "sha1",
-				principal: "12345",
+			expected: Signature{
+				Algorithm: "sha1",
+				Value:     "12345",
 			},
 			event: &event.Tokenize{
 				Header:     xmidtHeader,
@@ -183,9 +190,9 @@ func TestTokenize(t *testing.T) {
 				AcceptSHA1(),
 				AcceptNoHash(),
 			},
-			expected: token{
-				alg:       "sha1",
-				principal: "12345",
+			expected: Signature{
+				Algorithm: "sha1",
+				Value:     "12345",
 			},
 			event: &event.Tokenize{
 				Header:     webpaHeader,
@@ -195,9 +202,9 @@ func TestTokenize(t *testing.T) {
 		}, {
 			description: "no header with that name",
 			opt:         AcceptNoHash(),
-			expected: token{
-				alg:       "none",
-				principal: "",
+			expected: Signature{
+				Algorithm: "none",
+				Value:     "",
 			},
 			event: &event.Tokenize{
 				Algorithms: []string{"none"},
@@ -211,9 +218,9 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 			opt: AcceptNoHash(),
-			expected: token{
-				alg:       "none",
-				principal: "",
+			expected: Signature{
+				Algorithm: "none",
+				Value:     "",
 			},
 			event: &event.Tokenize{
 				Header:     webpaHeader,
@@ -348,9 +355,9 @@ func TestAuthorize(t *testing.T) {
 				AcceptSHA1(),
 				AcceptedSecrets("123456"),
 			},
-			token: token{
-				alg:       "sha1",
-				principal: "f76a55b14b2b3bd08116b4ee857dd6439b507317",
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "f76a55b14b2b3bd08116b4ee857dd6439b507317",
 			},
 			event: &event.Authorize{
 				Algorithm: "sha1",
@@ -364,9 +371,9 @@ func TestAuthorize(t *testing.T) {
 				AcceptSHA1(),
 				AcceptedSecrets("123456"),
 			},
-			token: token{
-				alg:       "sha1",
-				principal: "0000",
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "0000",
 			},
 			event: &event.Authorize{
 				Algorithm: "sha1",
@@ -382,9 +389,9 @@ func TestAuthorize(t *testing.T) {
 				AcceptSHA1(),
 				AcceptedSecrets("123456"),
 			},
-			token: token{
-				alg:       "sha1",
-				principal: "823688dafca7393d24c871a2da98a84d8732e927",
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "823688dafca7393d24c871a2da98a84d8732e927",
 			},
 			event: &event.Authorize{
 				Algorithm: "sha1",
@@ -395,18 +402,18 @@ func TestAuthorize(t *testing.T) {
 				AcceptSHA1(),
 				AcceptedSecrets("123456"),
 			},
-			token: token{
-				alg:       "sha1",
-				principal: "823688dafca7393d24c871a2da98a84d8732e927",
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "823688dafca7393d24c871a2da98a84d8732e927",
 			},
 			event: &event.Authorize{
 				Algorithm: "sha1",
 			},
 		}, {
 			description: "invalid principle",
-			token: token{
-				alg:       "sha1",
-				principal: "f", // invalid because it needs to be 2 characters.
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "f", // invalid because it needs to be 2 characters.
 			},
 			expectedErr: ErrInvalidSignature,
 			event: &event.Authorize{
@@ -420,9 +427,9 @@ func TestAuthorize(t *testing.T) {
 			input: http.Request{
 				Body: io.NopCloser(strings.NewReader("foo")),
 			},
-			token: token{
-				alg:       "sha1",
-				principal: "f0",
+			token: Signature{
+				Algorithm: "sha1",
+				Value:     "f0",
 			},
 			expectedErr: ErrNotAcceptedHash,
 			event: &event.Authorize{
@@ -472,6 +479,74 @@ func TestAuthorize(t *testing.T) {
 	}
 }
 
+func TestEventRequestDetails(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var tEvent event.Tokenize
+	var aEvent event.Authorize
+
+	r := validWHR
+	whl, err := New("http://example.com", &r,
+		AcceptSHA1(),
+		AcceptedSecrets("abcdef", "123456"),
+		SecretFingerprints(),
+		WithTokenizeEventListener(event.TokenizeFunc(func(e event.Tokenize) {
+			tEvent = e
+		})),
+		WithAuthorizeEventListener(event.AuthorizeFunc(func(e event.Authorize) {
+			aEvent = e
+		})),
+	)
+	require.NoError(err)
+	require.NotNil(whl)
+
+	sig := "f76a55b14b2b3bd08116b4ee857dd6439b507317"
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/events?a=b", strings.NewReader("foo"))
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("X-Webpa-Transaction-Id", "txn-1")
+	req.Header.Set(xmidtHeader, "sha1="+sig)
+
+	tok, err := whl.Tokenize(req)
+	require.NoError(err)
+
+	err = whl.Authorize(req, tok)
+	require.NoError(err)
+
+	assert.False(tEvent.At.IsZero())
+	assert.Equal("10.0.0.1:1234", tEvent.RemoteAddr)
+	assert.Equal("/events", tEvent.Path)
+	assert.Equal(int64(3), tEvent.ContentLength)
+	assert.Equal("application/msgpack", tEvent.ContentType)
+	assert.Equal("txn-1", tEvent.RequestID)
+
+	assert.False(aEvent.At.IsZero())
+	assert.Equal("sha1", aEvent.Algorithm)
+	assert.Equal(1, aEvent.SecretIndex)
+	assert.Equal(fingerprint("123456"), aEvent.SecretFingerprint)
+	assert.Len(aEvent.SecretFingerprint, 16)
+	assert.Equal("10.0.0.1:1234", aEvent.RemoteAddr)
+	assert.Equal("/events", aEvent.Path)
+	assert.Equal(int64(3), aEvent.ContentLength)
+	assert.Equal("application/msgpack", aEvent.ContentType)
+	assert.Equal("txn-1", aEvent.RequestID)
+
+	// Neither the secrets nor the signature may leak into the events.
+	for _, str := range []string{tEvent.String(), aEvent.String()} {
+		assert.NotContains(str, sig)
+		assert.NotContains(str, "123456")
+	}
+
+	// A request id header takes precedence and failures report no secret.
+	req.Header.Set("X-Request-Id", "req-1")
+	err = whl.Authorize(req, Signature{Algorithm: "sha1", Value: "0000"})
+	assert.ErrorIs(err, ErrInvalidSignature)
+	assert.Equal("req-1", aEvent.RequestID)
+	assert.Equal(-1, aEvent.SecretIndex)
+	assert.Empty(aEvent.SecretFingerprint)
+}
+
 func TestListener_Accept(t *testing.T) {
 	tests := []struct {
 		description  string
@@ -512,6 +587,115 @@ func TestListener_Accept(t *testing.T) {
 	}
 }
 
+func TestListener_Update(t *testing.T) {
+	client := &http.Client{}
+
+	tests := []struct {
+		description string
+		r           *webhook.Registration
+		opts        []Option
+		expectedErr error
+		interval    time.Duration
+		client      *http.Client
+		secret      string
+	}{
+		{
+			description: "replace the registration, keeping the secret",
+			r: &webhook.Registration{
+				Events:   []string{"bar"},
+				Duration: webhook.CustomDuration(10 * time.Minute),
+			},
+			client: http.DefaultClient,
+			secret: "secret1",
+		}, {
+			description: "replace the registration and secret with options",
+			r: &webhook.Registration{
+				Config: webhook.DeliveryConfig{
+					Secret: "secret2",
+				},
+				Duration: webhook.CustomDuration(10 * time.Minute),
+			},
+			opts:     []Option{Interval(time.Minute), HTTPClient(client)},
+			interval: time.Minute,
+			client:   client,
+			secret:   "secret2",
+		}, {
+			description: "nil registration",
+			expectedErr: ErrInput,
+		}, {
+			description: "invalid registration",
+			r:           &webhook.Registration{},
+			expectedErr: ErrInput,
+		}, {
+			description: "option that cannot be updated",
+			r:           &webhook.Registration{Duration: webhook.CustomDuration(time.Minute)},
+			opts:        []Option{AcceptSHA1()},
+			expectedErr: ErrInput,
+		}, {
+			description: "invalid option",
+			r:           &webhook.Registration{Duration: webhook.CustomDuration(time.Minute)},
+			opts:        []Option{Interval(-time.Minute)},
+			expectedErr: ErrInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			r := webhook.Registration{
+				Config: webhook.DeliveryConfig{
+					Secret: "secret1",
+				},
+				Duration: webhook.CustomDuration(5 * time.Minute),
+			}
+			l, err := New("http://example.com", &r)
+			require.NotNil(l)
+			require.NoError(err)
+
+			before := string(l.body)
+			err = l.Update(tc.r, tc.opts...)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Equal(before, string(l.body))
+				assert.Equal(&r, registrationOf(l))
+				return
+			}
+
+			require.NoError(err)
+			assert.Equal(tc.r, registrationOf(l))
+			assert.Equal(tc.secret, registrationOf(l).Config.Secret)
+			assert.Contains(string(l.body), tc.secret)
+			assert.Equal(tc.interval, l.interval)
+			assert.Equal(tc.client, l.client)
+			assert.Len(l.update, 1)
+		})
+	}
+}
+
+func TestListener_UpdateReplacesOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := webhook.Registration{
+		Duration: webhook.CustomDuration(5 * time.Minute),
+	}
+	l, err := New("http://example.com", &r,
+		AcceptedSecrets("bar"),
+		Interval(time.Minute),
+	)
+	require.NoError(err)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(l.Update(&r, Interval(time.Duration(i)*time.Hour), HTTPClient(nil)))
+	}
+	require.NoError(l.Update(&r, Once()))
+
+	assert.Equal("Listener(URL(http://example.com), AcceptedSecrets(***), "+
+		"HTTPClient(nil), Once())", l.String())
+}
+
 func TestListener_String(t *testing.T) {
 	tests := []struct {
 		description string
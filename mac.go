@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/hmac"
+	"hash"
+	"sync"
+)
+
+// keyedMAC reuses the keyed HMAC state for a single hash and secret.  Keying
+// an HMAC hashes the secret into the inner and outer pads, which is the bulk
+// of the cost for typical callback bodies, so the keyed state is pooled and
+// reset between uses instead of created for every callback.
+type keyedMAC struct {
+	pool sync.Pool
+}
+
+func newKeyedMAC(h func() hash.Hash, secret string) *keyedMAC {
+	key := []byte(secret)
+	return &keyedMAC{
+		pool: sync.Pool{
+			New: func() any {
+				return hmac.New(h, key)
+			},
+		},
+	}
+}
+
+// sum appends the HMAC of the body to b and returns the resulting slice.
+func (k *keyedMAC) sum(b, body []byte) []byte {
+	mac := k.pool.Get().(hash.Hash)
+	defer k.pool.Put(mac)
+
+	mac.Reset()
+	mac.Write(body)
+	return mac.Sum(b)
+}
+
+// buildMACs creates the keyed HMACs for each accepted hash and secret.  The
+// HMACs of each hash are in the same order as the secrets.  Hashes without a
+// hash function, such as "none", have no HMACs so they never match.
+func buildMACs(hashes map[string]func() hash.Hash, secrets []string) map[string][]*keyedMAC {
+	macs := make(map[string][]*keyedMAC, len(hashes))
+	for name, h := range hashes {
+		if h == nil {
+			macs[name] = nil
+			continue
+		}
+
+		list := make([]*keyedMAC, 0, len(secrets))
+		for _, secret := range secrets {
+			list = append(list, newKeyedMAC(h, secret))
+		}
+		macs[name] = list
+	}
+	return macs
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedMAC(t *testing.T) {
+	assert := assert.New(t)
+
+	mac := newKeyedMAC(sha256.New, "secret")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				body := []byte(strconv.Itoa(i*1000 + j))
+				want := hmac.New(sha256.New, []byte("secret"))
+				want.Write(body)
+				assert.Equal(want.Sum(nil), mac.sum(nil, body))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The sum is appended to the slice provided.
+	want := hmac.New(sha256.New, []byte("secret"))
+	want.Write([]byte("body"))
+	assert.Equal(append([]byte("prefix"), want.Sum(nil)...), mac.sum([]byte("prefix"), []byte("body")))
+}
+
+func TestAccept_RebuildsMACs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	l, err := New("http://example.com", &validWHR,
+		AcceptSHA256(),
+		AcceptSHA1(),
+		AcceptedSecrets("a", "b"),
+	)
+	require.NoError(err)
+	assert.Len(l.macs["sha256"], 2)
+	assert.Len(l.macs["sha1"], 2)
+
+	l.Accept([]string{"c"})
+	assert.Len(l.macs["sha256"], 1)
+
+	l.AcceptSecrets([]Secret{{Value: "d"}, {Value: "e"}, {Value: "f"}})
+	assert.Len(l.macs["sha256"], 3)
+
+	_, _, _, err = l.getMACs("md5")
+	assert.ErrorIs(err, ErrNotAcceptedHash)
+}
+
+// benchmarkSecrets returns the secrets accepted by the benchmarks.  The last
+// secret signs the requests, so every secret is tried.
+func benchmarkSecrets(n int) []string {
+	secrets := make([]string, n)
+	for i := range secrets {
+		secrets[i] = fmt.Sprintf("secret-%d-%s", i, bytes.Repeat([]byte("x"), 32))
+	}
+	return secrets
+}
+
+func BenchmarkAuthorize(b *testing.B) {
+	for _, secrets := range []int{1, 10, 100} {
+		for _, size := range []int{256, 64 << 10, 1 << 20} {
+			b.Run(fmt.Sprintf("secrets=%d/body=%d", secrets, size), func(b *testing.B) {
+				accepted := benchmarkSecrets(secrets)
+				body := bytes.Repeat([]byte("a"), size)
+
+				mac := hmac.New(sha256.New, []byte(accepted[len(accepted)-1]))
+				mac.Write(body)
+				token := NewSignature("sha256", hex.EncodeToString(mac.Sum(nil)))
+
+				l, err := New("http://example.com", &validWHR,
+					AcceptSHA256(),
+					AcceptedSecrets(accepted...),
+				)
+				require.NoError(b, err)
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+					if err := l.Authorize(r, token); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkHMAC compares keying a new HMAC for every callback with reusing the
+// pooled keyed HMAC.
+func BenchmarkHMAC(b *testing.B) {
+	for _, secrets := range []int{1, 10, 100} {
+		for _, size := range []int{256, 64 << 10} {
+			accepted := benchmarkSecrets(secrets)
+			body := bytes.Repeat([]byte("a"), size)
+
+			b.Run(fmt.Sprintf("new/secrets=%d/body=%d", secrets, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, secret := range accepted {
+						var h hash.Hash = hmac.New(sha256.New, []byte(secret))
+						h.Write(body)
+						_ = h.Sum(nil)
+					}
+				}
+			})
+
+			b.Run(fmt.Sprintf("pooled/secrets=%d/body=%d", secrets, size), func(b *testing.B) {
+				macs := buildMACs(map[string]func() hash.Hash{"sha256": sha256.New}, accepted)["sha256"]
+				var buf [64]byte
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, mac := range macs {
+						_ = mac.sum(buf[:0], body)
+					}
+				}
+			})
+		}
+	}
+}
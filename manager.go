@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// Manager manages multiple named webhook registrations that share the same
+// http client, request decorators, accepted secrets and event listeners.  Each
+// registration is registered and renewed independently, and every registration
+// event includes the name of the registration it is for.
+//
+// Callbacks for any of the registrations are validated using the Tokenize()
+// and Authorize() methods of the Manager.
+type Manager struct {
+	names     []string
+	listeners map[string]*Listener
+
+	// primary is the listener that owns the event listeners and validates
+	// the callbacks.  The other listeners forward their registration events
+	// to it.
+	primary *Listener
+}
+
+// NewManager creates a new Manager for the named registrations.  The options
+// are applied to every registration.  The Name() option is set for each
+// registration based on the key in the map.
+func NewManager(url string, regs map[string]*webhook.Registration, opts ...Option) (*Manager, error) {
+	if len(regs) == 0 {
+		return nil, fmt.Errorf("%w: at least one registration is required", ErrInput)
+	}
+
+	names := make([]string, 0, len(regs))
+	for name := range regs {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: registration name is required", ErrInput)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := Manager{
+		names:     names,
+		listeners: make(map[string]*Listener, len(names)),
+	}
+
+	// Only the primary listener gets the event listeners and the async event
+	// options so each event is dispatched exactly once.
+	shared := make([]Option, 0, len(opts))
+	for _, opt := range opts {
+		switch opt.(type) {
+		case *withRegistrationEventListenerOption,
+			*withAuthorizeEventListenerOption,
+			*withTokenizeEventListenerOption,
+			*asyncEventsOption:
+			continue
+		}
+		shared = append(shared, opt)
+	}
+
+	forward := WithRegistrationEventListener(event.RegistrationFunc(
+		func(e event.Registration) {
+			_ = dispatch(m.primary, e)
+		},
+	))
+
+	for i, name := range names {
+		// Each listener keeps its options, so each needs its own slice.
+		var lOpts []Option
+		if i == 0 {
+			lOpts = append(lOpts, opts...)
+		} else {
+			lOpts = append(lOpts, shared...)
+			lOpts = append(lOpts, forward)
+		}
+		lOpts = append(lOpts, Name(name))
+
+		l, err := New(url, regs[name], lOpts...)
+		if err != nil {
+			for _, created := range m.listeners {
+				created.Stop()
+			}
+			return nil, errors.Join(err, fmt.Errorf("%w: registration '%s'", ErrInput, name))
+		}
+
+		if i == 0 {
+			m.primary = l
+		}
+		m.listeners[name] = l
+	}
+
+	return &m, nil
+}
+
+// Names returns the sorted names of the registrations.
+func (m *Manager) Names() []string {
+	names := make([]string, len(m.names))
+	copy(names, m.names)
+	return names
+}
+
+// Listener returns the Listener for the named registration or nil if there is
+// no registration with the name.  The Listener can be used to Update() or
+// Register() the registration independently.
+func (m *Manager) Listener(name string) *Listener {
+	return m.listeners[name]
+}
+
+// Register registers all the webhooks using the optional specified secret.
+// See Listener.Register() for details.  The errors from all the registrations
+// are joined together.
+func (m *Manager) Register(ctx context.Context, secret ...string) error {
+	var errs []error
+	for _, name := range m.names {
+		if err := m.listeners[name].Register(ctx, secret...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops all the webhook registrations.
+func (m *Manager) Stop() {
+	// The primary listener is stopped last so any events forwarded from the
+	// other listeners are delivered.
+	for _, name := range m.names[1:] {
+		m.listeners[name].Stop()
+	}
+	m.primary.Stop()
+}
+
+// Accept defines the entire list of secrets to accept for the webhook callbacks.
+// See Listener.Accept() for details.
+func (m *Manager) Accept(secrets []string) {
+	for _, name := range m.names {
+		m.listeners[name].Accept(secrets)
+	}
+}
+
+// AcceptSecrets defines the entire list of labeled secrets to accept for the
+// webhook callbacks.  See Listener.AcceptSecrets() for details.
+func (m *Manager) AcceptSecrets(secrets []Secret) {
+	for _, name := range m.names {
+		m.listeners[name].AcceptSecrets(secrets)
+	}
+}
+
+// Tokenize parses the token from the request header.  See Listener.Tokenize()
+// for details.
+func (m *Manager) Tokenize(r *http.Request) (Token, error) {
+	return m.primary.Tokenize(r)
+}
+
+// Authorize validates that the request body matches the hash and secret
+// provided in the token.  See Listener.Authorize() for details.
+func (m *Manager) Authorize(r *http.Request, t Token) error {
+	return m.primary.Authorize(r, t)
+}
+
+// AuthorizeSource validates that the request comes from an allowed source
+// address.  See Listener.AuthorizeSource() for details.
+func (m *Manager) AuthorizeSource(r *http.Request) error {
+	return m.primary.AuthorizeSource(r)
+}
+
+// AuthorizeMatch validates the request the same as Authorize and describes
+// the secret that matched.  See Listener.AuthorizeMatch() for details.
+func (m *Manager) AuthorizeMatch(r *http.Request, t Token) (*Match, error) {
+	return m.primary.AuthorizeMatch(r, t)
+}
+
+// AddRegistrationEventListener adds an event listener for the registration
+// events of all the registrations.  The returned function can be called to
+// remove the listener.
+func (m *Manager) AddRegistrationEventListener(listener event.RegistrationListener) CancelEventListenerFunc {
+	return m.primary.AddRegistrationEventListener(listener)
+}
+
+// AddTokenizeEventListener adds an event listener to the manager.  The
+// returned function can be called to remove the listener.
+func (m *Manager) AddTokenizeEventListener(listener event.TokenizeListener) CancelEventListenerFunc {
+	return m.primary.AddTokenizeEventListener(listener)
+}
+
+// AddAuthorizeEventListener adds an event listener to the manager.  The
+// returned function can be called to remove the listener.
+func (m *Manager) AddAuthorizeEventListener(listener event.AuthorizeListener) CancelEventListenerFunc {
+	return m.primary.AddAuthorizeEventListener(listener)
+}
+
+// DroppedEvents returns the number of events that were discarded because the
+// asynchronous event buffer was full.
+func (m *Manager) DroppedEvents() uint64 {
+	return m.primary.DroppedEvents()
+}
+
+// String returns a string representation of the manager and the listeners for
+// each registration.
+func (m *Manager) String() string {
+	buf := strings.Builder{}
+
+	buf.WriteString("Manager(")
+	for i, name := range m.names {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(m.listeners[name].String())
+	}
+	buf.WriteString(")")
+
+	return buf.String()
+}
@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+func TestNewManager(t *testing.T) {
+	tests := []struct {
+		description string
+		regs        map[string]*webhook.Registration
+		opts        []Option
+		expectedErr error
+	}{
+		{
+			description: "two registrations",
+			regs: map[string]*webhook.Registration{
+				"b": {Duration: webhook.CustomDuration(time.Minute)},
+				"a": {Duration: webhook.CustomDuration(time.Minute)},
+			},
+			opts: []Option{
+				AcceptSHA1(),
+				AsyncEvents(10, OverflowBlock),
+				WithRegistrationEventListener(event.RegistrationFunc(func(event.Registration) {})),
+			},
+		}, {
+			description: "no registrations",
+			expectedErr: ErrInput,
+		}, {
+			description: "empty name",
+			regs: map[string]*webhook.Registration{
+				" ": {Duration: webhook.CustomDuration(time.Minute)},
+			},
+			expectedErr: ErrInput,
+		}, {
+			description: "invalid registration",
+			regs: map[string]*webhook.Registration{
+				"a": {Duration: webhook.CustomDuration(time.Minute)},
+				"b": {},
+			},
+			opts:        []Option{AsyncEvents(10, OverflowBlock)},
+			expectedErr: ErrInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			m, err := NewManager("http://example.com", tc.regs, tc.opts...)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(m)
+				return
+			}
+
+			require.NoError(err)
+			require.NotNil(m)
+			defer m.Stop()
+
+			assert.Equal([]string{"a", "b"}, m.Names())
+			assert.Nil(m.Listener("c"))
+
+			a, b := m.Listener("a"), m.Listener("b")
+			require.NotNil(a)
+			require.NotNil(b)
+			assert.Same(a, m.primary)
+			assert.Equal("a", a.name)
+			assert.Equal("b", b.name)
+
+			// Only the primary listener has the async dispatcher.
+			assert.NotNil(a.events)
+			assert.Nil(b.events)
+
+			assert.Equal("Manager("+
+				"Listener(URL(http://example.com), AcceptSHA1(), AsyncEvents(10, Block), WithRegistrationEventListener(lstnr), Name(a)), "+
+				"Listener(URL(http://example.com), AcceptSHA1(), WithRegistrationEventListener(lstnr), Name(b)))",
+				m.String())
+		})
+	}
+}
+
+func TestManagerUsage(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var m sync.Mutex
+	events := map[string][]string{}
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(err)
+				r.Body.Close()
+
+				var reg webhook.Registration
+				err = json.Unmarshal(body, &reg)
+				assert.NoError(err)
+				assert.Equal("secret1", reg.Config.Secret)
+				assert.Equal("Bearer token", r.Header.Get("Authorization"))
+
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	var regEvents []event.Registration
+	var authEvents int
+
+	mgr, err := NewManager(server.URL,
+		map[string]*webhook.Registration{
+			"status": {
+				Events:   []string{"device-status"},
+				Duration: webhook.CustomDuration(5 * time.Minute),
+			},
+			"online": {
+				Events:   []string{"online"},
+				Duration: webhook.CustomDuration(5 * time.Minute),
+			},
+		},
+		AcceptSHA1(),
+		AcceptedSecrets("123456"),
+		DecorateRequest(DecoratorFunc(func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer token")
+			return nil
+		})),
+		WithRegistrationEventListener(event.RegistrationFunc(func(e event.Registration) {
+			m.Lock()
+			defer m.Unlock()
+			regEvents = append(regEvents, e)
+			events[e.Name] = append(events[e.Name], e.Name)
+		})),
+		WithAuthorizeEventListener(event.AuthorizeFunc(func(event.Authorize) {
+			m.Lock()
+			defer m.Unlock()
+			authEvents++
+		})),
+	)
+	require.NoError(err)
+	require.NotNil(mgr)
+
+	err = mgr.Register(context.Background(), "secret1")
+	require.NoError(err)
+
+	m.Lock()
+	assert.Len(regEvents, 2)
+	assert.Len(events["status"], 1)
+	assert.Len(events["online"], 1)
+	for _, e := range regEvents {
+		assert.NoError(e.Err)
+	}
+	m.Unlock()
+
+	// A registration can be registered independently.
+	err = mgr.Listener("online").Register(context.Background())
+	require.NoError(err)
+
+	m.Lock()
+	assert.Len(events["online"], 2)
+	m.Unlock()
+
+	// Callbacks are validated once using the shared secrets.
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("foo"))
+	req.Header.Set(xmidtHeader, "sha1=f76a55b14b2b3bd08116b4ee857dd6439b507317")
+
+	tok, err := mgr.Tokenize(req)
+	require.NoError(err)
+	assert.NoError(mgr.Authorize(req, tok))
+
+	mgr.Accept([]string{"abcdef"})
+	assert.ErrorIs(mgr.Authorize(req, tok), ErrInvalidSignature)
+
+	mgr.AcceptSecrets([]Secret{{Label: "old", Value: "123456"}})
+	match, err := mgr.AuthorizeMatch(req, tok)
+	require.NoError(err)
+	assert.Equal("old", match.ID())
+
+	m.Lock()
+	assert.Equal(3, authEvents)
+	m.Unlock()
+
+	// Listeners added later apply to all the registrations.
+	var count int
+	cancel := mgr.AddRegistrationEventListener(event.RegistrationFunc(func(event.Registration) {
+		count++
+	}))
+	require.NoError(mgr.Register(context.Background()))
+	assert.Equal(2, count)
+	cancel()
+
+	assert.NotNil(mgr.AddTokenizeEventListener(event.TokenizeFunc(func(event.Tokenize) {})))
+	assert.NotNil(mgr.AddAuthorizeEventListener(event.AuthorizeFunc(func(event.Authorize) {})))
+	assert.Equal(uint64(0), mgr.DroppedEvents())
+
+	mgr.Stop()
+}
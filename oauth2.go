@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRefreshAhead is how long before the access token expires that a new
+// token is obtained.
+const defaultRefreshAhead = 30 * time.Second
+
+// ClientCredentialsConfig configures the OAuth2 client credentials grant used
+// to obtain access tokens for the registration requests.
+type ClientCredentialsConfig struct {
+	// TokenURL is the OAuth2 token endpoint.  Required.
+	TokenURL string
+
+	// ClientID is the OAuth2 client id.  Required.
+	ClientID string
+
+	// ClientSecret is the OAuth2 client secret.
+	ClientSecret string
+
+	// Scopes are the optional scopes to request.
+	Scopes []string
+
+	// EndpointParams are additional parameters sent to the token endpoint,
+	// such as an audience.
+	EndpointParams url.Values
+
+	// RefreshAhead is how long before the access token expires that a new
+	// token is obtained.  If 0, 30 seconds is used.
+	RefreshAhead time.Duration
+
+	// Client is the http client used for the token requests.  If nil, the
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// ClientCredentials is a Decorator that authenticates the registration
+// requests with access tokens obtained from an OAuth2 token endpoint using the
+// client credentials grant.
+//
+// Access tokens are cached and a new token is obtained ahead of the expiration.
+// If obtaining a new token fails while the cached token is still valid, the
+// cached token is used.  If the webhook registration endpoint rejects the
+// token, a new token is obtained and the registration is attempted once more.
+type ClientCredentials struct {
+	cfg ClientCredentialsConfig
+	now func() time.Time
+
+	m         sync.Mutex
+	token     string
+	tokenType string
+	expires   time.Time
+}
+
+var _ Refresher = (*ClientCredentials)(nil)
+
+// NewClientCredentials creates a new ClientCredentials decorator.
+func NewClientCredentials(cfg ClientCredentialsConfig) (*ClientCredentials, error) {
+	if cfg.TokenURL == "" {
+		return nil, fmt.Errorf("%w: token url is required", ErrInput)
+	}
+	if _, err := url.ParseRequestURI(cfg.TokenURL); err != nil {
+		return nil, fmt.Errorf("%w: invalid token url: %w", ErrInput, err)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("%w: client id is required", ErrInput)
+	}
+	if cfg.RefreshAhead < 0 {
+		return nil, fmt.Errorf("%w: refresh ahead must be greater than or equal to 0", ErrInput)
+	}
+	if cfg.RefreshAhead == 0 {
+		cfg.RefreshAhead = defaultRefreshAhead
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &ClientCredentials{
+		cfg: cfg,
+		now: time.Now,
+	}, nil
+}
+
+// Decorate adds the access token to the request, obtaining a new token if
+// needed.  Failures to obtain a token are returned as ErrDecoratorFailed.
+func (c *ClientCredentials) Decorate(r *http.Request) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	now := c.now()
+	if c.token == "" || (!c.expires.IsZero() && !now.Add(c.cfg.RefreshAhead).Before(c.expires)) {
+		err := c.fetch(r)
+		if err != nil && (c.token == "" || !now.Before(c.expires)) {
+			c.token = ""
+			return err
+		}
+	}
+
+	r.Header.Set("Authorization", c.tokenType+" "+c.token)
+	return nil
+}
+
+// Refresh discards the cached access token so a new token is obtained by the
+// next call to Decorate.
+func (c *ClientCredentials) Refresh() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.token = ""
+	c.expires = time.Time{}
+}
+
+func (c *ClientCredentials) String() string {
+	return "ClientCredentials(" + c.cfg.TokenURL + ", " + c.cfg.ClientID + ", ***)"
+}
+
+// tokenResponse is the response of the OAuth2 token endpoint, which includes
+// the error fields if the request failed.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// fetch obtains a new access token using the context of the registration
+// request.  The lock must be held by the caller.
+func (c *ClientCredentials) fetch(r *http.Request) error {
+	form := url.Values{}
+	for k, v := range c.cfg.EndpointParams {
+		form[k] = v
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(c.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, c.cfg.TokenURL,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%w: unable to create the token request: %w", ErrDecoratorFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+
+	at := c.now()
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: token request failed: %w", ErrDecoratorFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return fmt.Errorf("%w: unable to read the token response: %w", ErrDecoratorFailed, err)
+	}
+
+	var tr tokenResponse
+	jsonErr := json.Unmarshal(body, &tr)
+
+	if resp.StatusCode != http.StatusOK {
+		if tr.Error != "" {
+			return fmt.Errorf("%w: token endpoint returned %d: %s: %s",
+				ErrDecoratorFailed, resp.StatusCode, tr.Error, tr.ErrorDescription)
+		}
+		return fmt.Errorf("%w: token endpoint returned %d", ErrDecoratorFailed, resp.StatusCode)
+	}
+
+	if jsonErr != nil {
+		return fmt.Errorf("%w: invalid token response: %w", ErrDecoratorFailed, jsonErr)
+	}
+	if tr.AccessToken == "" {
+		return fmt.Errorf("%w: token response has no access token", ErrDecoratorFailed)
+	}
+
+	c.token = tr.AccessToken
+	c.tokenType = tr.TokenType
+	if c.tokenType == "" || strings.EqualFold(c.tokenType, "bearer") {
+		c.tokenType = "Bearer"
+	}
+	c.expires = time.Time{}
+	if tr.ExpiresIn > 0 {
+		c.expires = at.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// tokenServer is a fake OAuth2 token endpoint that issues numbered tokens.
+type tokenServer struct {
+	m         sync.Mutex
+	issued    int
+	status    int
+	expiresIn int
+	form      map[string]string
+	user      string
+	pass      string
+}
+
+func (ts *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	_ = r.ParseForm()
+	ts.form = map[string]string{}
+	for k := range r.PostForm {
+		ts.form[k] = r.PostForm.Get(k)
+	}
+	ts.user, ts.pass, _ = r.BasicAuth()
+
+	w.Header().Set("Content-Type", "application/json")
+	if ts.status != 0 && ts.status != http.StatusOK {
+		w.WriteHeader(ts.status)
+		fmt.Fprint(w, `{"error": "invalid_client", "error_description": "unknown client"}`)
+		return
+	}
+
+	ts.issued++
+	fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`,
+		ts.issued, ts.expiresIn)
+}
+
+func (ts *tokenServer) count() int {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+	return ts.issued
+}
+
+func TestNewClientCredentials(t *testing.T) {
+	tests := []struct {
+		description string
+		cfg         ClientCredentialsConfig
+		expectedErr error
+	}{
+		{
+			description: "valid",
+			cfg: ClientCredentialsConfig{
+				TokenURL: "http://example.com/token",
+				ClientID: "id",
+			},
+		}, {
+			description: "missing token url",
+			cfg: ClientCredentialsConfig{
+				ClientID: "id",
+			},
+			expectedErr: ErrInput,
+		}, {
+			description: "invalid token url",
+			cfg: ClientCredentialsConfig{
+				TokenURL: "::invalid",
+				ClientID: "id",
+			},
+			expectedErr: ErrInput,
+		}, {
+			description: "missing client id",
+			cfg: ClientCredentialsConfig{
+				TokenURL: "http://example.com/token",
+			},
+			expectedErr: ErrInput,
+		}, {
+			description: "negative refresh ahead",
+			cfg: ClientCredentialsConfig{
+				TokenURL:     "http://example.com/token",
+				ClientID:     "id",
+				RefreshAhead: -1,
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c, err := NewClientCredentials(tc.cfg)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(c)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal("ClientCredentials(http://example.com/token, id, ***)", c.String())
+		})
+	}
+}
+
+func TestClientCredentials_Decorate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ts := &tokenServer{expiresIn: 300}
+	server := httptest.NewServer(ts)
+	defer server.Close()
+
+	c, err := NewClientCredentials(ClientCredentialsConfig{
+		TokenURL:     server.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+		EndpointParams: map[string][]string{
+			"audience": {"webhooks"},
+		},
+	})
+	require.NoError(err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	decorate := func() string {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+		require.NoError(c.Decorate(r))
+		return r.Header.Get("Authorization")
+	}
+
+	assert.Equal("Bearer token-1", decorate())
+	assert.Equal("id", ts.user)
+	assert.Equal("secret", ts.pass)
+	assert.Equal(map[string]string{
+		"grant_type": "client_credentials",
+		"scope":      "read write",
+		"audience":   "webhooks",
+	}, ts.form)
+
+	// The token is cached.
+	assert.Equal("Bearer token-1", decorate())
+	assert.Equal(1, ts.count())
+
+	// A new token is obtained ahead of the expiration.
+	now = now.Add(300*time.Second - defaultRefreshAhead)
+	assert.Equal("Bearer token-2", decorate())
+
+	// The cached token is used if it is still valid when a new token cannot
+	// be obtained.
+	ts.m.Lock()
+	ts.status = http.StatusUnauthorized
+	ts.m.Unlock()
+	now = now.Add(300*time.Second - defaultRefreshAhead)
+	assert.Equal("Bearer token-2", decorate())
+
+	// Once the token expires the failure is returned with the details.
+	now = now.Add(defaultRefreshAhead)
+	r := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+	err = c.Decorate(r)
+	assert.ErrorIs(err, ErrDecoratorFailed)
+	assert.ErrorContains(err, "401")
+	assert.ErrorContains(err, "invalid_client: unknown client")
+	assert.Empty(r.Header.Get("Authorization"))
+
+	// Refresh discards the cached token.
+	ts.m.Lock()
+	ts.status = http.StatusOK
+	ts.m.Unlock()
+	assert.Equal("Bearer token-3", decorate())
+	c.Refresh()
+	assert.Equal("Bearer token-4", decorate())
+}
+
+func TestClientCredentials_RetryUnauthorized(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ts := &tokenServer{expiresIn: 300}
+	tokens := httptest.NewServer(ts)
+	defer tokens.Close()
+
+	var m sync.Mutex
+	var auths []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				m.Lock()
+				defer m.Unlock()
+
+				auths = append(auths, r.Header.Get("Authorization"))
+				// Only accept the second token issued.
+				if r.Header.Get("Authorization") != "Bearer token-2" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	c, err := NewClientCredentials(ClientCredentialsConfig{
+		TokenURL: tokens.URL,
+		ClientID: "id",
+	})
+	require.NoError(err)
+
+	var events []event.Registration
+	whl, err := New(
+		server.URL,
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		DecorateRequest(c),
+		WithRegistrationEventListener(event.RegistrationFunc(
+			func(e event.Registration) {
+				events = append(events, e)
+			}),
+		),
+	)
+	require.NoError(err)
+
+	// The rejected token is refreshed and the registration is retried once.
+	err = whl.Register(context.Background())
+	assert.NoError(err)
+	assert.Equal([]string{"Bearer token-1", "Bearer token-2"}, auths)
+	require.Len(events, 1)
+	assert.Equal(http.StatusOK, events[0].StatusCode)
+
+	// Only one retry is attempted.
+	auths = nil
+	events = nil
+	c.Refresh()
+	err = whl.Register(context.Background())
+	assert.ErrorIs(err, ErrRegistrationUnauthorized)
+	assert.Equal([]string{"Bearer token-3", "Bearer token-4"}, auths)
+	require.Len(events, 1)
+	assert.Equal(http.StatusUnauthorized, events[0].StatusCode)
+}
@@ -6,9 +6,13 @@ package listener
 import (
 	"crypto/sha1" //nolint:gosec
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"hash"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -19,7 +23,8 @@ import (
 // Interval is an option that sets the interval to wait between webhook
 // registration attempts.  The default is to only register once.  This option
 // must be greater than or equal to 0.  A value of 0 will cause the webhook to
-// only be registered once.
+// only be registered once.  If the webhook server reports that a registration
+// expires sooner than the interval, it is renewed before it expires instead.
 func Interval(i time.Duration) Option {
 	return &intervalOption{
 		text:     fmt.Sprintf("Interval(%s)", i),
@@ -53,9 +58,106 @@ func (i intervalOption) String() string {
 	return i.text
 }
 
+// Name is an option that provides the name of the registration.  The name is
+// included in every registration event.
+func Name(name string) Option {
+	return &nameOption{
+		name: name,
+	}
+}
+
+type nameOption struct {
+	name string
+}
+
+func (n nameOption) apply(lis *Listener) error {
+	lis.name = n.name
+	return nil
+}
+
+func (n nameOption) String() string {
+	return "Name(" + n.name + ")"
+}
+
+// AlternateURLs is an option that provides additional webhook registration
+// endpoints.  If the registration fails because an endpoint is unavailable,
+// the next endpoint is tried.  By default the url provided to New() is tried
+// first followed by the alternate urls in the order provided.  Endpoints that
+// recently failed are tried after the healthy endpoints.  Each url may only be
+// provided once, including the url provided to New().
+func AlternateURLs(urls ...string) Option {
+	return &alternateURLsOption{
+		urls: urls,
+	}
+}
+
+type alternateURLsOption struct {
+	urls []string
+}
+
+func (a alternateURLsOption) apply(lis *Listener) error {
+	for _, url := range a.urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			return fmt.Errorf("%w, alternate url cannot be empty", ErrInput)
+		}
+		if err := lis.endpoints.add(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a alternateURLsOption) String() string {
+	return "AlternateURLs(" + strings.Join(a.urls, ", ") + ")"
+}
+
+// RoundRobin is an option that causes each registration to start with the
+// next webhook registration endpoint instead of always starting with the
+// first one.  This spreads the registrations across the endpoints provided by
+// New() and AlternateURLs().
+func RoundRobin() Option {
+	return &roundRobinOption{}
+}
+
+type roundRobinOption struct{}
+
+func (roundRobinOption) apply(lis *Listener) error {
+	lis.endpoints.roundRobin = true
+	return nil
+}
+
+func (roundRobinOption) String() string {
+	return "RoundRobin()"
+}
+
+// FanOut is an option that causes the webhook to be registered with every
+// endpoint provided by New() and AlternateURLs() concurrently instead of
+// failing over between them.  This is useful when each datacenter runs its own
+// webhook server.  An event is dispatched for each endpoint, so event
+// listeners may be called concurrently.  The registration only succeeds if
+// every endpoint accepts it.  RoundRobin() has no effect when FanOut() is
+// used.
+func FanOut() Option {
+	return &fanOutOption{}
+}
+
+type fanOutOption struct{}
+
+func (fanOutOption) apply(lis *Listener) error {
+	lis.endpoints.fanOut = true
+	return nil
+}
+
+func (fanOutOption) String() string {
+	return "FanOut()"
+}
+
 // HTTPClient is an option that provides the http client to use for the
 // webhook listener registration to use.  A nil value will cause the default
 // http client to be used.
+//
+// This option cannot be combined with the ClientCertificates() option.
 func HTTPClient(c *http.Client) Option {
 	return &httpClientOption{
 		client: c,
@@ -64,6 +166,10 @@ func HTTPClient(c *http.Client) Option {
 
 type httpClientOption struct {
 	client *http.Client
+
+	// certs reloads the client certificate of the client if it has one, so
+	// reload failures are dispatched as events.
+	certs *certReloader
 }
 
 func (h httpClientOption) apply(lis *Listener) error {
@@ -72,6 +178,10 @@ func (h httpClientOption) apply(lis *Listener) error {
 		return nil
 	}
 
+	if h.certs != nil {
+		h.certs.notify(certReloadFailed(lis))
+	}
+
 	lis.client = h.client
 	return nil
 }
@@ -83,6 +193,91 @@ func (h httpClientOption) String() string {
 	return "HTTPClient(nil)"
 }
 
+// ClientCertificates is an option that configures the http client used for
+// the registration requests to use mutual TLS.  The client certificate and key
+// are loaded from the files provided and loaded again whenever the files
+// change, so the certificate can be rotated without restarting the listener.
+// If a changed certificate cannot be loaded, a registration event with
+// ErrCertificateReloadFailed is dispatched and the previous certificate
+// continues to be used.
+//
+// The optional caFile is used to verify the webhook registration endpoint
+// instead of the system certificate pool.
+//
+// This option replaces the http client, so it cannot be combined with the
+// HTTPClient() option.
+func ClientCertificates(certFile, keyFile string, caFile ...string) Option {
+	c := clientCertificatesOption{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if len(caFile) > 0 {
+		c.caFile = caFile[0]
+	}
+	return &c
+}
+
+type clientCertificatesOption struct {
+	certFile string
+	keyFile  string
+	caFile   string
+}
+
+func (c clientCertificatesOption) apply(lis *Listener) error {
+	if c.certFile == "" || c.keyFile == "" {
+		return fmt.Errorf("%w: a certificate and key file are required", ErrInput)
+	}
+
+	cr, err := newCertReloader(c.certFile, c.keyFile, certReloadFailed(lis))
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("%w: unable to load the client certificate", ErrInput))
+	}
+
+	tlsConfig := tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: cr.GetClientCertificate,
+	}
+
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return errors.Join(err, fmt.Errorf("%w: unable to read the ca file", ErrInput))
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: no certificates found in the ca file", ErrInput)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tlsConfig
+
+	lis.client = &http.Client{
+		Transport: transport,
+	}
+	return nil
+}
+
+// certReloadFailed returns the function that dispatches a registration event
+// when a changed client certificate cannot be loaded.
+func certReloadFailed(lis *Listener) func(error) {
+	return func(err error) {
+		_ = dispatch(lis, event.Registration{
+			Name: lis.name,
+			At:   time.Now(),
+			Err:  errors.Join(err, ErrCertificateReloadFailed),
+		})
+	}
+}
+
+func (c clientCertificatesOption) String() string {
+	if c.caFile != "" {
+		return "ClientCertificates(" + c.certFile + ", " + c.keyFile + ", " + c.caFile + ")"
+	}
+	return "ClientCertificates(" + c.certFile + ", " + c.keyFile + ")"
+}
+
 // The Decorator type is an adapter to allow the use of ordinary functions as
 // decorators.  If f is a function with the appropriate signature,
 // DecoratorFunc(f) is a Decorator that calls f.
@@ -103,6 +298,13 @@ type Decorator interface {
 	Decorate(*http.Request) error
 }
 
+// A Refresher is a Decorator with credentials that can be refreshed.  If the
+// webhook registration endpoint responds with 401 Unauthorized, the credentials
+// of every Refresher are refreshed and the registration is attempted once more.
+type Refresher interface {
+	Refresh()
+}
+
 // DecorateRequest is an option that provides the function to use to decorate
 // the http request before it is sent to the webhook registration endpoint.
 // This is useful for adding headers or other information to the request.
@@ -151,6 +353,7 @@ type acceptedSecretsOption struct {
 
 func (s acceptedSecretsOption) apply(lis *Listener) error {
 	lis.acceptedSecrets = append(lis.acceptedSecrets, s.secret...)
+	lis.secretLabels = append(lis.secretLabels, make([]string, len(s.secret))...)
 	return nil
 }
 
@@ -161,6 +364,79 @@ func (s acceptedSecretsOption) String() string {
 	return "AcceptedSecrets(***, ...)"
 }
 
+// AcceptedLabeledSecrets is an option that provides the list of labeled
+// secrets accepted by the webhook listener when validating the callback event.
+// The label of the secret that matched is reported in the authorize event.  A
+// valid hash (or multiple) must be provided as well.
+func AcceptedLabeledSecrets(secrets ...Secret) Option {
+	return &acceptedLabeledSecretsOption{
+		secrets: secrets,
+	}
+}
+
+type acceptedLabeledSecretsOption struct {
+	secrets []Secret
+}
+
+func (s acceptedLabeledSecretsOption) apply(lis *Listener) error {
+	for _, secret := range s.secrets {
+		lis.acceptedSecrets = append(lis.acceptedSecrets, secret.Value)
+		lis.secretLabels = append(lis.secretLabels, secret.Label)
+	}
+	return nil
+}
+
+func (s acceptedLabeledSecretsOption) String() string {
+	buf := strings.Builder{}
+
+	buf.WriteString("AcceptedLabeledSecrets(")
+	for i, secret := range s.secrets {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if secret.Label == "" {
+			buf.WriteString("***")
+			continue
+		}
+		buf.WriteString(secret.Label)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// SecretFingerprints is an option that includes a fingerprint of the matching
+// secret in the authorize events and the Match, so unlabeled secrets can be
+// told apart.  Without this option only the label and index of the matching
+// secret are reported.
+//
+// The fingerprint is an unsalted hash of the secret, so a secret with little
+// entropy can be recovered from it by guessing.  Only use this option with
+// long, randomly generated secrets, and prefer labeling the secrets with
+// AcceptedLabeledSecrets() instead.
+//
+// USE WITH CAUTION.
+func SecretFingerprints() Option {
+	return &secretFingerprintsOption{}
+}
+
+type secretFingerprintsOption struct{}
+
+func (secretFingerprintsOption) apply(lis *Listener) error {
+	lis.fingerprints = true
+	return nil
+}
+
+func (secretFingerprintsOption) String() string {
+	return "SecretFingerprints()"
+}
+
+// knownHashes are the hashes with built-in support, keyed by the name used in
+// the signature headers.
+var knownHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
 // AcceptNoHash enables the use of no hash for the webhook listener
 // callback validation.
 //
@@ -178,7 +454,7 @@ func AcceptSHA1() Option {
 	return &hashOption{
 		text: "AcceptSHA1()",
 		name: "sha1",
-		fn:   sha1.New,
+		fn:   knownHashes["sha1"],
 	}
 }
 
@@ -188,7 +464,7 @@ func AcceptSHA256() Option {
 	return &hashOption{
 		text: "AcceptSHA256()",
 		name: "sha256",
-		fn:   sha256.New,
+		fn:   knownHashes["sha256"],
 	}
 }
 
@@ -379,3 +655,43 @@ func (a withRegistrationEventListenerOption) String() string {
 	}
 	return "WithRegistrationEventListener(lstnr)"
 }
+
+// AsyncEvents is an option that causes events to be delivered to the event
+// listeners from a background goroutine instead of the calling goroutine.  The
+// size is the number of events that can be buffered and must be greater than
+// 0.  The policy determines what happens when the buffer is full.  Discarded
+// events are counted and available via Listener.DroppedEvents().
+//
+// Stop() must be called to release the background goroutine.
+func AsyncEvents(size int, policy OverflowPolicy) Option {
+	return &asyncEventsOption{
+		size:   size,
+		policy: policy,
+	}
+}
+
+type asyncEventsOption struct {
+	size   int
+	policy OverflowPolicy
+}
+
+func (a asyncEventsOption) apply(lis *Listener) error {
+	if a.size <= 0 {
+		return fmt.Errorf("%w, event buffer size must be greater than 0", ErrInput)
+	}
+
+	switch a.policy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		return fmt.Errorf("%w, unknown overflow policy", ErrInput)
+	}
+
+	lis.async = true
+	lis.eventBufferSize = a.size
+	lis.overflowPolicy = a.policy
+	return nil
+}
+
+func (a asyncEventsOption) String() string {
+	return fmt.Sprintf("AsyncEvents(%d, %s)", a.size, a.policy)
+}
@@ -29,6 +29,18 @@ func TestOptionStrings(t *testing.T) {
 		}, {
 			in:       Once(),
 			expected: "Once()",
+		}, {
+			in:       Name("foo"),
+			expected: "Name(foo)",
+		}, {
+			in:       AlternateURLs("http://a.example.com", "http://b.example.com"),
+			expected: "AlternateURLs(http://a.example.com, http://b.example.com)",
+		}, {
+			in:       RoundRobin(),
+			expected: "RoundRobin()",
+		}, {
+			in:       FanOut(),
+			expected: "FanOut()",
 		}, {
 			in:       HTTPClient(http.DefaultClient),
 			expected: "HTTPClient(client)",
@@ -41,12 +53,18 @@ func TestOptionStrings(t *testing.T) {
 		}, {
 			in:       DecorateRequest(DecoratorFunc(func(*http.Request) error { return nil })),
 			expected: "DecorateRequest(DecoratorFunc(fn))",
+		}, {
+			in:       SecretFingerprints(),
+			expected: "SecretFingerprints()",
 		}, {
 			in:       AcceptedSecrets("foo"),
 			expected: "AcceptedSecrets(***)",
 		}, {
 			in:       AcceptedSecrets("foo", "bar"),
 			expected: "AcceptedSecrets(***, ...)",
+		}, {
+			in:       AcceptedLabeledSecrets(Secret{Label: "old", Value: "foo"}, Secret{Value: "bar"}),
+			expected: "AcceptedLabeledSecrets(old, ***)",
 		}, {
 			in:       AcceptNoHash(),
 			expected: "AcceptNoHash()",
@@ -89,6 +107,15 @@ func TestOptionStrings(t *testing.T) {
 		}, {
 			in:       WithRegistrationEventListener(nil),
 			expected: "WithRegistrationEventListener(nil)",
+		}, {
+			in:       AsyncEvents(10, OverflowDropOldest),
+			expected: "AsyncEvents(10, DropOldest)",
+		}, {
+			in:       ClientCertificates("cert.pem", "key.pem"),
+			expected: "ClientCertificates(cert.pem, key.pem)",
+		}, {
+			in:       ClientCertificates("cert.pem", "key.pem", "ca.pem"),
+			expected: "ClientCertificates(cert.pem, key.pem, ca.pem)",
 		},
 	}
 
@@ -128,6 +155,47 @@ func TestHTTPClient(t *testing.T) {
 	commonNewTest(t, tests)
 }
 
+func TestAlternateURLs(t *testing.T) {
+	tests := []newTest{
+		{
+			description: "assert alternate urls work",
+			r:           validWHR,
+			opt:         AlternateURLs("http://a.example.com", "http://b.example.com"),
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Equal([]string{
+					"http://example.com",
+					"http://a.example.com",
+					"http://b.example.com",
+				}, l.endpoints.urls())
+			},
+		}, {
+			description: "assert an empty url errors",
+			r:           validWHR,
+			opt:         AlternateURLs(" "),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert a duplicate url errors",
+			r:           validWHR,
+			opt:         AlternateURLs("http://a.example.com", "http://a.example.com"),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert the url cannot be repeated",
+			r:           validWHR,
+			opt:         AlternateURLs("http://example.com"),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert duplicates across options error",
+			r:           validWHR,
+			opts: []Option{
+				AlternateURLs("http://a.example.com"),
+				AlternateURLs("http://a.example.com"),
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+}
+
 func TestSecrets(t *testing.T) {
 	tests := []newTest{
 		{
@@ -153,6 +221,19 @@ func TestSecrets(t *testing.T) {
 				AcceptedSecrets("bar"),
 			},
 			check: vadorAcceptedSecrets("foo", "car", "cat", "bar"),
+		}, {
+			description: "assert AcceptedLabeledSecrets() works with AcceptedSecrets()",
+			r:           validWHR,
+			opts: []Option{
+				AcceptedSecrets("foo"),
+				AcceptedLabeledSecrets(Secret{Label: "new", Value: "bar"}),
+			},
+			checks: []vador{
+				vadorAcceptedSecrets("foo", "bar"),
+				func(assert *assert.Assertions, l *Listener) {
+					assert.Equal([]string{"", "new"}, l.secretLabels)
+				},
+			},
 		},
 	}
 	commonNewTest(t, tests)
@@ -405,3 +486,36 @@ func TestWithRegistrationEventListener(t *testing.T) {
 
 	assert.NotNil(t, cancel)
 }
+
+func TestAsyncEvents(t *testing.T) {
+	tests := []newTest{
+		{
+			description: "assert events are synchronous by default",
+			r:           validWHR,
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Nil(l.events)
+			},
+		}, {
+			description: "assert AsyncEvents() works",
+			r:           validWHR,
+			opt:         AsyncEvents(10, OverflowDropNewest),
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.NotNil(l.events)
+				assert.Equal(10, cap(l.events.queue))
+				assert.Equal(OverflowDropNewest, l.events.policy)
+				l.Stop()
+			},
+		}, {
+			description: "assert a zero buffer size errors",
+			r:           validWHR,
+			opt:         AsyncEvents(0, OverflowBlock),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an unknown policy errors",
+			r:           validWHR,
+			opt:         AsyncEvents(10, OverflowPolicy(99)),
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+}
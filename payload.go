@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/xmidt-org/webhook-schema"
+)
+
+// Payload is a webhook registration payload.  It allows registration schemas
+// other than webhook.Registration to be registered, validated and renewed by
+// the webhook listener.
+//
+// Expiration may be called concurrently with SetSecret, so it must not depend
+// on the secret.
+type Payload interface {
+	// Validate validates the payload.  The interval is the time between
+	// registrations or 0 if the webhook is only registered once.
+	Validate(interval time.Duration) error
+
+	// Secret returns the secret used to sign the webhook callbacks.
+	Secret() string
+
+	// SetSecret sets the secret used to sign the webhook callbacks.
+	SetSecret(secret string)
+
+	// Marshal returns the JSON body of the registration request.
+	Marshal() ([]byte, error)
+
+	// Expiration returns when a registration accepted at the given time
+	// expires.
+	Expiration(at time.Time) time.Time
+}
+
+// registrationPayload adapts a webhook.Registration to the Payload interface.
+type registrationPayload struct {
+	r    *webhook.Registration
+	opts []webhook.Option
+}
+
+// Validate validates the registration using the webhook.Options provided and
+// the options implied by the interval.
+func (p *registrationPayload) Validate(interval time.Duration) error {
+	vOpts := []webhook.Option{
+		webhook.ValidateRegistrationDuration(0),
+	}
+
+	if interval != 0 {
+		vOpts = append(vOpts, webhook.NoUntil())
+	}
+	vOpts = append(vOpts, p.opts...)
+
+	return p.r.Validate(vOpts...)
+}
+
+func (p *registrationPayload) Secret() string {
+	return p.r.Config.Secret
+}
+
+func (p *registrationPayload) SetSecret(secret string) {
+	p.r.Config.Secret = secret
+}
+
+func (p *registrationPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p.r)
+}
+
+// Expiration returns when the registration expires.  A registration without a
+// duration expires at the time it specifies.
+func (p *registrationPayload) Expiration(at time.Time) time.Time {
+	if p.r.Duration == 0 && !p.r.Until.IsZero() {
+		return p.r.Until
+	}
+	return at.Add(time.Duration(p.r.Duration))
+}
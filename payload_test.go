@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// payloadV2 is a registration payload in the style of the newer webhook
+// schemas.
+type payloadV2 struct {
+	CanonicalName string   `json:"canonical_name"`
+	ReceiverURLs  []string `json:"receiver_urls"`
+	SecretValue   string   `json:"secret"`
+	TTL           string   `json:"ttl"`
+}
+
+func (p *payloadV2) Validate(time.Duration) error {
+	if len(p.ReceiverURLs) == 0 {
+		return fmt.Errorf("at least one receiver url is required")
+	}
+	if _, err := time.ParseDuration(p.TTL); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *payloadV2) Secret() string          { return p.SecretValue }
+func (p *payloadV2) SetSecret(secret string) { p.SecretValue = secret }
+func (p *payloadV2) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *payloadV2) Expiration(at time.Time) time.Time {
+	ttl, _ := time.ParseDuration(p.TTL)
+	return at.Add(ttl)
+}
+
+func TestNewWithPayload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	got := make(chan payloadV2, 10)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(err)
+				r.Body.Close()
+
+				var p payloadV2
+				assert.NoError(json.Unmarshal(body, &p))
+				got <- p
+
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	_, err := NewWithPayload(server.URL, nil)
+	assert.ErrorIs(err, ErrInput)
+
+	_, err = NewWithPayload(server.URL, &payloadV2{TTL: "1m"})
+	assert.ErrorIs(err, ErrInput)
+
+	var evnt event.Registration
+	l, err := NewWithPayload(server.URL,
+		&payloadV2{
+			CanonicalName: "example",
+			ReceiverURLs:  []string{"http://example.com/events"},
+			SecretValue:   "secret1",
+			TTL:           "10m",
+		},
+		WithRegistrationEventListener(event.RegistrationFunc(func(e event.Registration) {
+			evnt = e
+		})),
+	)
+	require.NoError(err)
+	require.NotNil(l)
+
+	err = l.Register(context.Background(), "secret2")
+	require.NoError(err)
+
+	p := <-got
+	assert.Equal("example", p.CanonicalName)
+	assert.Equal("secret2", p.SecretValue)
+	assert.Equal(evnt.At.Add(10*time.Minute), evnt.Until)
+
+	// The payload can be replaced, keeping the secret.
+	err = l.UpdatePayload(&payloadV2{
+		CanonicalName: "renamed",
+		ReceiverURLs:  []string{"http://example.com/events"},
+		TTL:           "1m",
+	})
+	require.NoError(err)
+
+	err = l.Register(context.Background())
+	require.NoError(err)
+
+	p = <-got
+	assert.Equal("renamed", p.CanonicalName)
+	assert.Equal("secret2", p.SecretValue)
+	assert.Equal(evnt.At.Add(time.Minute), evnt.Until)
+
+	assert.ErrorIs(l.UpdatePayload(nil), ErrInput)
+	assert.ErrorIs(l.UpdatePayload(&payloadV2{TTL: "1m"}), ErrInput)
+}
+
+func TestRegistrationPayload_Expiration(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	until := now.Add(time.Hour)
+
+	p := registrationPayload{r: &webhook.Registration{
+		Duration: webhook.CustomDuration(5 * time.Minute),
+	}}
+	assert.Equal(now.Add(5*time.Minute), p.Expiration(now))
+
+	p = registrationPayload{r: &webhook.Registration{Until: until}}
+	assert.Equal(until, p.Expiration(now))
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseBody limits how much of a registration response is read.
+const maxResponseBody = 64 * 1024
+
+// registrationResponse holds the details the webhook server reported about a
+// registration.
+type registrationResponse struct {
+	id       string
+	until    time.Time
+	warnings []string
+}
+
+// responseBody is the union of the known registration response formats.  The
+// first non-empty field of each kind is used.
+type responseBody struct {
+	ID             string          `json:"id"`
+	RegistrationID string          `json:"registration_id"`
+	Until          string          `json:"until"`
+	Expires        string          `json:"expires"`
+	Expiration     string          `json:"expiration"`
+	Warnings       []string        `json:"warnings"`
+	Warning        json.RawMessage `json:"warning"`
+}
+
+// parseResponse parses the known formats of the registration response body.
+// Unknown formats, such as plain text, result in an empty response.
+func parseResponse(body []byte) registrationResponse {
+	var rr registrationResponse
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' {
+		return rr
+	}
+
+	var rb responseBody
+	if err := json.Unmarshal(body, &rb); err != nil {
+		return rr
+	}
+
+	rr.id = firstNonEmpty(rb.ID, rb.RegistrationID)
+
+	for _, s := range []string{rb.Until, rb.Expires, rb.Expiration} {
+		if s == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			rr.until = t
+			break
+		}
+	}
+
+	rr.warnings = append(rr.warnings, rb.Warnings...)
+	if len(rb.Warning) > 0 {
+		var warning string
+		var warnings []string
+		if err := json.Unmarshal(rb.Warning, &warning); err == nil && warning != "" {
+			rr.warnings = append(rr.warnings, warning)
+		} else if err := json.Unmarshal(rb.Warning, &warnings); err == nil {
+			rr.warnings = append(rr.warnings, warnings...)
+		}
+	}
+
+	return rr
+}
+
+// classify returns the error for a registration response status code, or nil
+// if the registration was accepted.  Any 2xx status code is a success.  A 4xx
+// status code is a rejection unless it means the request may succeed later,
+// which is the case for 408, 409, 425 and 429.
+func classify(statusCode int) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return errors.Join(ErrRegistrationFailed, ErrRegistrationUnauthorized)
+	case http.StatusForbidden:
+		// The credentials are valid, but not allowed to register.
+		return errors.Join(ErrRegistrationFailed, ErrRegistrationUnauthorized, ErrRegistrationRejected)
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly,
+		http.StatusTooManyRequests:
+		return errors.Join(ErrRegistrationFailed, ErrRegistrationUnavailable)
+	}
+
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return nil
+	case statusCode >= 400 && statusCode < 500:
+		return errors.Join(ErrRegistrationFailed, ErrRegistrationRejected)
+	case statusCode >= 500:
+		return errors.Join(ErrRegistrationFailed, ErrRegistrationUnavailable)
+	}
+	return ErrRegistrationFailed
+}
+
+// maxRetryAfter is the longest delay honoured from a Retry-After header.
+// Longer delays are shortened so a misbehaving server cannot stop the
+// registrations indefinitely.
+const maxRetryAfter = time.Hour
+
+// retryAfter returns the delay requested by the Retry-After header of 429 and
+// 503 responses.  The header may be a number of seconds or an HTTP date.  The
+// delay is limited to maxRetryAfter.  If there is no valid delay, 0 is
+// returned.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests &&
+		resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	header := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if header == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseInt(header, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		// Compare in seconds so large values cannot overflow.
+		if secs > int64(maxRetryAfter/time.Second) {
+			return maxRetryAfter
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(header); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return min(d, maxRetryAfter)
+		}
+	}
+
+	return 0
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResponse(t *testing.T) {
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		body        string
+		want        registrationResponse
+	}{
+		{
+			description: "empty body",
+		}, {
+			description: "plain text body",
+			body:        "OK",
+		}, {
+			description: "invalid json",
+			body:        `{"id": `,
+		}, {
+			description: "id, until and warnings",
+			body:        `{"id": "abc", "until": "2030-01-02T03:04:05Z", "warnings": ["one", "two"]}`,
+			want: registrationResponse{
+				id:       "abc",
+				until:    until,
+				warnings: []string{"one", "two"},
+			},
+		}, {
+			description: "alternate field names",
+			body:        ` {"registration_id": "abc", "expires": "2030-01-02T03:04:05Z", "warning": "one"}`,
+			want: registrationResponse{
+				id:       "abc",
+				until:    until,
+				warnings: []string{"one"},
+			},
+		}, {
+			description: "expiration and a list of warnings",
+			body:        `{"expiration": "2030-01-02T03:04:05Z", "warning": ["one"]}`,
+			want: registrationResponse{
+				until:    until,
+				warnings: []string{"one"},
+			},
+		}, {
+			description: "invalid expiration is ignored",
+			body:        `{"id": "abc", "until": "tomorrow", "expires": "2030-01-02T03:04:05Z"}`,
+			want: registrationResponse{
+				id:    "abc",
+				until: until,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got := parseResponse([]byte(tc.body))
+			assert.Equal(tc.want.id, got.id)
+			assert.True(tc.want.until.Equal(got.until))
+			assert.Equal(tc.want.warnings, got.warnings)
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusCreated},
+		{status: http.StatusAccepted},
+		{status: http.StatusNoContent},
+		{status: http.StatusUnauthorized, want: ErrRegistrationUnauthorized},
+		{status: http.StatusForbidden, want: ErrRegistrationUnauthorized},
+		{status: http.StatusForbidden, want: ErrRegistrationRejected},
+		{status: http.StatusBadRequest, want: ErrRegistrationRejected},
+		{status: http.StatusNotFound, want: ErrRegistrationRejected},
+		{status: http.StatusUnprocessableEntity, want: ErrRegistrationRejected},
+		{status: http.StatusGone, want: ErrRegistrationRejected},
+		{status: http.StatusRequestTimeout, want: ErrRegistrationUnavailable},
+		{status: http.StatusConflict, want: ErrRegistrationUnavailable},
+		{status: http.StatusTooEarly, want: ErrRegistrationUnavailable},
+		{status: http.StatusTooManyRequests, want: ErrRegistrationUnavailable},
+		{status: http.StatusPaymentRequired, want: ErrRegistrationRejected},
+		{status: http.StatusNotAcceptable, want: ErrRegistrationRejected},
+		{status: http.StatusPreconditionFailed, want: ErrRegistrationRejected},
+		{status: http.StatusRequestHeaderFieldsTooLarge, want: ErrRegistrationRejected},
+		{status: http.StatusUnavailableForLegalReasons, want: ErrRegistrationRejected},
+		{status: http.StatusTeapot, want: ErrRegistrationRejected},
+		{status: http.StatusInternalServerError, want: ErrRegistrationUnavailable},
+		{status: http.StatusServiceUnavailable, want: ErrRegistrationUnavailable},
+		{status: http.StatusNotModified, want: ErrRegistrationFailed},
+	}
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := classify(tc.status)
+			if tc.want == nil {
+				assert.NoError(err)
+				return
+			}
+
+			assert.ErrorIs(err, ErrRegistrationFailed)
+			assert.ErrorIs(err, tc.want)
+			if tc.want == ErrRegistrationFailed || tc.status == http.StatusUnauthorized {
+				assert.False(permanent(err))
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		status      int
+		header      string
+		want        time.Duration
+	}{
+		{
+			description: "seconds",
+			status:      http.StatusTooManyRequests,
+			header:      "120",
+			want:        2 * time.Minute,
+		}, {
+			description: "http date",
+			status:      http.StatusServiceUnavailable,
+			header:      now.Add(time.Minute).Format(http.TimeFormat),
+			want:        time.Minute,
+		}, {
+			description: "http date in the past",
+			status:      http.StatusServiceUnavailable,
+			header:      now.Add(-time.Minute).Format(http.TimeFormat),
+		}, {
+			description: "seconds longer than the maximum",
+			status:      http.StatusTooManyRequests,
+			header:      "99999999",
+			want:        maxRetryAfter,
+		}, {
+			description: "seconds that overflow a duration",
+			status:      http.StatusTooManyRequests,
+			header:      "9223372036854775807",
+			want:        maxRetryAfter,
+		}, {
+			description: "seconds that overflow an integer",
+			status:      http.StatusTooManyRequests,
+			header:      "99999999999999999999999",
+		}, {
+			description: "http date after the maximum",
+			status:      http.StatusServiceUnavailable,
+			header:      now.Add(1000 * time.Hour).Format(http.TimeFormat),
+			want:        maxRetryAfter,
+		}, {
+			description: "negative seconds",
+			status:      http.StatusTooManyRequests,
+			header:      "-1",
+		}, {
+			description: "invalid header",
+			status:      http.StatusTooManyRequests,
+			header:      "soon",
+		}, {
+			description: "no header",
+			status:      http.StatusTooManyRequests,
+		}, {
+			description: "other status codes are ignored",
+			status:      http.StatusInternalServerError,
+			header:      "120",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			resp := http.Response{
+				StatusCode: tc.status,
+				Header:     http.Header{},
+			}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+
+			assert.Equal(t, tc.want, retryAfter(&resp, now))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Secret is a secret accepted when validating webhook callbacks along with an
+// optional label that identifies it without revealing it.  The label is useful
+// for tracking which secret is in use while secrets are being rotated.
+type Secret struct {
+	// Label is the operator supplied name of the secret.  It is included in
+	// events and must not contain sensitive information.
+	Label string
+
+	// Value is the shared secret.
+	Value string
+}
+
+// ID returns the label of the secret, which is empty if the secret is not
+// labeled.
+func (s Secret) ID() string {
+	return s.Label
+}
+
+// String returns the label of the secret, never the secret itself.
+func (s Secret) String() string {
+	if s.Label == "" {
+		return "Secret(***)"
+	}
+	return "Secret(" + s.Label + ")"
+}
+
+// Match describes the accepted secret that validated a webhook callback.
+type Match struct {
+	// Algorithm is the algorithm used to validate the callback.
+	Algorithm string
+
+	// SecretIndex is the index of the matching secret in the list of
+	// accepted secrets at the time the callback was validated.
+	SecretIndex int
+
+	// SecretLabel is the operator supplied label of the matching secret if
+	// one was provided.
+	SecretLabel string
+
+	// SecretFingerprint is a fingerprint of the matching secret.  It is only
+	// set when the SecretFingerprints() option is used.
+	SecretFingerprint string
+
+	// ClientCertificate is the subject of the client certificate that was
+	// authorized, if the client certificate was checked.
+	ClientCertificate string
+
+	// KeyID is the id of the public key that verified the signature, if the
+	// callback was signed with a private key.
+	KeyID string
+}
+
+// ID returns the label of the matching secret if present, otherwise the
+// fingerprint of the secret if fingerprints are enabled.
+func (m Match) ID() string {
+	if m.SecretLabel != "" {
+		return m.SecretLabel
+	}
+	return m.SecretFingerprint
+}
+
+// fingerprint returns a short identifier for the secret.  It is an unsalted
+// hash, so it is only safe to include in events and logs for secrets with
+// enough entropy that they cannot be guessed.  See SecretFingerprints().
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+func TestSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	labeled := Secret{Label: "old", Value: "123456"}
+	assert.Equal("old", labeled.ID())
+	assert.Equal("Secret(old)", labeled.String())
+
+	unlabeled := Secret{Value: "123456"}
+	assert.Empty(unlabeled.ID())
+	assert.Equal("Secret(***)", unlabeled.String())
+
+	assert.Equal("old", Match{SecretLabel: "old", SecretFingerprint: "abc"}.ID())
+	assert.Equal("abc", Match{SecretFingerprint: "abc"}.ID())
+}
+
+func TestAuthorizeMatch(t *testing.T) {
+	// The signature of "foo" using the secret "123456".
+	sig := "f76a55b14b2b3bd08116b4ee857dd6439b507317"
+
+	tests := []struct {
+		description string
+		opts        []Option
+		accept      []Secret
+		expected    *Match
+		expectedErr error
+	}{
+		{
+			description: "labeled secret matches",
+			opts: []Option{
+				AcceptedLabeledSecrets(
+					Secret{Label: "new", Value: "abcdef"},
+					Secret{Label: "old", Value: "123456"},
+				),
+			},
+			expected: &Match{
+				Algorithm:   "sha1",
+				SecretIndex: 1,
+				SecretLabel: "old",
+			},
+		}, {
+			description: "unlabeled secret matches",
+			opts:        []Option{AcceptedSecrets("123456")},
+			expected: &Match{
+				Algorithm: "sha1",
+			},
+		}, {
+			description: "unlabeled secret matches with fingerprints",
+			opts:        []Option{AcceptedSecrets("123456"), SecretFingerprints()},
+			expected: &Match{
+				Algorithm:         "sha1",
+				SecretFingerprint: fingerprint("123456"),
+			},
+		}, {
+			description: "secrets replaced with labeled secrets",
+			opts:        []Option{AcceptedSecrets("abcdef")},
+			accept: []Secret{
+				{Label: "rotated", Value: "123456"},
+			},
+			expected: &Match{
+				Algorithm:   "sha1",
+				SecretLabel: "rotated",
+			},
+		}, {
+			description: "no secret matches",
+			opts: []Option{
+				AcceptedLabeledSecrets(Secret{Label: "new", Value: "abcdef"}),
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var got event.Authorize
+			opts := append(tc.opts,
+				AcceptSHA1(),
+				WithAuthorizeEventListener(event.AuthorizeFunc(func(e event.Authorize) {
+					got = e
+				})),
+			)
+
+			r := validWHR
+			l, err := New("http://example.com", &r, opts...)
+			require.NoError(err)
+			require.NotNil(l)
+
+			if tc.accept != nil {
+				l.AcceptSecrets(tc.accept)
+			}
+
+			req := http.Request{
+				Body: io.NopCloser(strings.NewReader("foo")),
+			}
+			match, err := l.AuthorizeMatch(&req, Signature{Algorithm: "sha1", Value: sig})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(match)
+				assert.Equal(-1, got.SecretIndex)
+				return
+			}
+
+			require.NoError(err)
+			assert.Equal(tc.expected, match)
+			assert.Equal(tc.expected.SecretIndex, got.SecretIndex)
+			assert.Equal(tc.expected.SecretLabel, got.SecretLabel)
+			assert.Equal(tc.expected.SecretFingerprint, got.SecretFingerprint)
+		})
+	}
+}
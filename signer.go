@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// RequestSignerConfig configures the signing of the registration requests.
+type RequestSignerConfig struct {
+	// Key is the HMAC key used to sign the requests.  Required.
+	Key Credential
+
+	// Hash is the name of the hash to use.  The built-in hashes are "sha1" and
+	// "sha256", the same as AcceptSHA1() and AcceptSHA256().  If empty,
+	// "sha256" is used.
+	Hash string
+
+	// HashFunc is the hash function to use with a custom Hash name, the same
+	// as AcceptCustom().
+	HashFunc func() hash.Hash
+
+	// Header is the header the signature is added to.  If empty, the
+	// Xmidt-Signature header is used.
+	Header string
+}
+
+// RequestSigner is a Decorator that signs the body of the registration
+// requests with HMAC.  The signature is added to the header in the same
+// format as the webhook callbacks use: <hash name>=<hex encoded signature>.
+type RequestSigner struct {
+	key    Credential
+	name   string
+	fn     func() hash.Hash
+	header string
+}
+
+// NewRequestSigner creates a new RequestSigner decorator.
+func NewRequestSigner(cfg RequestSignerConfig) (*RequestSigner, error) {
+	if cfg.Key == nil {
+		return nil, fmt.Errorf("%w: a signing key is required", ErrInput)
+	}
+
+	s := RequestSigner{
+		key:    cfg.Key,
+		name:   cfg.Hash,
+		fn:     cfg.HashFunc,
+		header: cfg.Header,
+	}
+
+	if s.name == "" {
+		s.name = "sha256"
+	}
+	if s.fn == nil {
+		s.fn = knownHashes[s.name]
+	}
+	if s.fn == nil {
+		return nil, fmt.Errorf("%w: unknown hash '%s'", ErrInput, s.name)
+	}
+	if s.header == "" {
+		s.header = xmidtHeader
+	}
+
+	return &s, nil
+}
+
+// Decorate signs the body of the request.  The body is read and restored so
+// the request can still be sent.
+func (s *RequestSigner) Decorate(r *http.Request) error {
+	key, err := s.key.Credential(r.Context())
+	if err != nil {
+		return fmt.Errorf("%w: unable to get the signing key: %w", ErrDecoratorFailed, err)
+	}
+	if key == "" {
+		return fmt.Errorf("%w: the signing key is empty", ErrDecoratorFailed)
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return fmt.Errorf("%w: unable to read the request body: %w", ErrDecoratorFailed, err)
+	}
+
+	mac := hmac.New(s.fn, []byte(key))
+	_, _ = mac.Write(body)
+
+	r.Header.Set(s.header, s.name+"="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+func (s *RequestSigner) String() string {
+	return "RequestSigner(" + s.name + ", " + s.header + ", ***)"
+}
+
+// readBody returns the body of the request without consuming it.  If the
+// request cannot provide a copy of the body, the body is read and replaced.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+
+	return body, nil
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webhook-schema"
+)
+
+func TestNewRequestSigner(t *testing.T) {
+	tests := []struct {
+		description string
+		cfg         RequestSignerConfig
+		str         string
+		expectedErr error
+	}{
+		{
+			description: "defaults",
+			cfg:         RequestSignerConfig{Key: StaticCredential("key")},
+			str:         "RequestSigner(sha256, Xmidt-Signature, ***)",
+		}, {
+			description: "sha1 with a custom header",
+			cfg: RequestSignerConfig{
+				Key:    StaticCredential("key"),
+				Hash:   "sha1",
+				Header: "X-Webpa-Signature",
+			},
+			str: "RequestSigner(sha1, X-Webpa-Signature, ***)",
+		}, {
+			description: "custom hash",
+			cfg: RequestSignerConfig{
+				Key:      StaticCredential("key"),
+				Hash:     "md5",
+				HashFunc: md5.New,
+			},
+			str: "RequestSigner(md5, Xmidt-Signature, ***)",
+		}, {
+			description: "no key",
+			expectedErr: ErrInput,
+		}, {
+			description: "unknown hash",
+			cfg: RequestSignerConfig{
+				Key:  StaticCredential("key"),
+				Hash: "md5",
+			},
+			expectedErr: ErrInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			s, err := NewRequestSigner(tc.cfg)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(s)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.str, s.String())
+		})
+	}
+}
+
+func TestRequestSigner_Decorate(t *testing.T) {
+	const body = `{"events":["foo"]}`
+	const sig = "sha256=55ebfe48aba38db27204dfe08598c87ce2bdb44ad80fdffe587f45360044164f"
+
+	tests := []struct {
+		description string
+		key         Credential
+		req         func() *http.Request
+		want        string
+		expectedErr error
+	}{
+		{
+			description: "body with GetBody",
+			key:         StaticCredential("key"),
+			req: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(body))
+				return r
+			},
+			want: sig,
+		}, {
+			description: "body without GetBody",
+			key:         StaticCredential("key"),
+			req: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(strings.NewReader(body)))
+				return r
+			},
+			want: sig,
+		}, {
+			description: "no body",
+			key:         StaticCredential("key"),
+			req: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+				return r
+			},
+			want: "sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0",
+		}, {
+			description: "empty key",
+			key:         StaticCredential(""),
+			req: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(body))
+				return r
+			},
+			expectedErr: ErrDecoratorFailed,
+		}, {
+			description: "failing key",
+			key: CredentialFunc(func(context.Context) (string, error) {
+				return "", errUnknown
+			}),
+			req: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(body))
+				return r
+			},
+			expectedErr: errUnknown,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			s, err := NewRequestSigner(RequestSignerConfig{Key: tc.key})
+			require.NoError(err)
+
+			r := tc.req()
+			err = s.Decorate(r)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.ErrorIs(err, ErrDecoratorFailed)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.want, r.Header.Get(xmidtHeader))
+
+			// The body is still available to send.
+			if r.Body != nil {
+				got, err := io.ReadAll(r.Body)
+				assert.NoError(err)
+				assert.Equal(body, string(got))
+			}
+		})
+	}
+}
+
+// TestRequestSigner_Register verifies a signed registration can be validated
+// the same way as a webhook callback.
+func TestRequestSigner_Register(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	verifier, err := New("http://example.com",
+		&webhook.Registration{
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		AcceptSHA256(),
+		AcceptedSecrets("key"),
+	)
+	require.NoError(err)
+
+	var authErr error
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				token, err := verifier.Tokenize(r)
+				if err == nil {
+					err = verifier.Authorize(r, token)
+				}
+				authErr = err
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	signer, err := NewRequestSigner(RequestSignerConfig{Key: StaticCredential("key")})
+	require.NoError(err)
+
+	whl, err := New(server.URL,
+		&webhook.Registration{
+			Events:   []string{"foo"},
+			Duration: webhook.CustomDuration(5 * time.Minute),
+		},
+		DecorateRequest(signer),
+	)
+	require.NoError(err)
+
+	assert.NoError(whl.Register(context.Background()))
+	assert.NoError(authErr)
+}
@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// AllowedSources is an option that restricts the webhook callbacks to the
+// source addresses provided.  Each source is a CIDR range or a single address.
+// Callbacks from other addresses are rejected with ErrSourceNotAllowed before
+// the signature is checked.  Multiple AllowedSources options are combined.
+func AllowedSources(cidrs ...string) Option {
+	return &sourcesOption{
+		name:  "AllowedSources",
+		cidrs: cidrs,
+	}
+}
+
+// TrustedProxies is an option that provides the addresses of the proxies that
+// are trusted to report the source address of the webhook callbacks using the
+// X-Forwarded-For header.  Each proxy is a CIDR range or a single address.
+// Only used with AllowedSources().
+func TrustedProxies(cidrs ...string) Option {
+	return &sourcesOption{
+		name:    "TrustedProxies",
+		cidrs:   cidrs,
+		proxies: true,
+	}
+}
+
+type sourcesOption struct {
+	name    string
+	cidrs   []string
+	proxies bool
+}
+
+func (s sourcesOption) apply(lis *Listener) error {
+	prefixes, err := parsePrefixes(s.cidrs)
+	if err != nil {
+		return err
+	}
+
+	if s.proxies {
+		lis.trustedProxies = append(lis.trustedProxies, prefixes...)
+		return nil
+	}
+
+	lis.allowedSources = append(lis.allowedSources, prefixes...)
+	return nil
+}
+
+func (s sourcesOption) String() string {
+	return s.name + "(" + strings.Join(s.cidrs, ", ") + ")"
+}
+
+// parsePrefixes parses the CIDR ranges or single addresses.
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("%w: at least one address range is required", ErrInput)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid address '%s'", ErrInput, cidr)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid address range '%s'", ErrInput, cidr)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizeSource validates that the request comes from an allowed source
+// address.  It is called by Authorize(), but can be used earlier in the
+// request handling to reject requests before reading the body.  If no
+// AllowedSources() are configured, all requests are allowed.
+//
+// Rejected requests dispatch an authorize event with ErrSourceNotAllowed.
+func (l *Listener) AuthorizeSource(r *http.Request) error {
+	if len(l.allowedSources) == 0 {
+		return nil
+	}
+
+	info := describe(r)
+	evnt := event.Authorize{
+		At:            time.Now(),
+		SecretIndex:   -1,
+		RemoteAddr:    info.remoteAddr,
+		Path:          info.path,
+		ContentLength: info.contentLength,
+		ContentType:   info.contentType,
+		RequestID:     info.requestID,
+	}
+
+	var err error
+	evnt.ClientIP, err = l.checkSource(r)
+	if err == nil {
+		return nil
+	}
+
+	evnt.Duration = time.Since(evnt.At)
+	evnt.Err = err
+	return dispatch(l, evnt)
+}
+
+// checkSource returns the client address of the request and an error if the
+// address is not allowed.  The X-Forwarded-For header is only used if the
+// request comes from a trusted proxy.
+func (l *Listener) checkSource(r *http.Request) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("%w: no request", ErrSourceNotAllowed)
+	}
+
+	client, err := l.clientAddr(r)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSourceNotAllowed, err)
+	}
+
+	if !contains(l.allowedSources, client) {
+		return client.String(), fmt.Errorf("%w: '%s'", ErrSourceNotAllowed, client)
+	}
+
+	return client.String(), nil
+}
+
+// clientAddr determines the client address of the request.  Starting with the
+// peer address, each trusted proxy is replaced by the address it reports,
+// working from the end of the X-Forwarded-For header.
+func (l *Listener) clientAddr(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address '%s'", r.RemoteAddr)
+	}
+	client = client.Unmap().WithZone("")
+
+	if !contains(l.trustedProxies, client) {
+		return client, nil
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid forwarded address '%s'", forwarded[i])
+		}
+
+		client = addr.Unmap().WithZone("")
+		if !contains(l.trustedProxies, client) {
+			break
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+func TestAuthorizeSource(t *testing.T) {
+	tests := []struct {
+		description string
+		opts        []Option
+		remoteAddr  string
+		forwarded   []string
+		clientIP    string
+		expectedErr error
+	}{
+		{
+			description: "no allowlist",
+			remoteAddr:  "192.0.2.1:1234",
+		}, {
+			description: "allowed range",
+			opts:        []Option{AllowedSources("10.0.0.0/8")},
+			remoteAddr:  "10.1.2.3:1234",
+		}, {
+			description: "allowed single address",
+			opts:        []Option{AllowedSources("10.1.2.3")},
+			remoteAddr:  "10.1.2.3:1234",
+		}, {
+			description: "allowed ipv6 range",
+			opts:        []Option{AllowedSources("2001:db8::/32")},
+			remoteAddr:  "[2001:db8::1]:1234",
+		}, {
+			description: "ipv4 mapped address",
+			opts:        []Option{AllowedSources("10.0.0.0/8")},
+			remoteAddr:  "[::ffff:10.1.2.3]:1234",
+		}, {
+			description: "not allowed",
+			opts:        []Option{AllowedSources("10.0.0.0/8", "172.16.0.0/12")},
+			remoteAddr:  "192.0.2.1:1234",
+			clientIP:    "192.0.2.1",
+			expectedErr: ErrSourceNotAllowed,
+		}, {
+			description: "invalid remote address",
+			opts:        []Option{AllowedSources("10.0.0.0/8")},
+			remoteAddr:  "invalid",
+			expectedErr: ErrSourceNotAllowed,
+		}, {
+			description: "forwarded header from an untrusted peer is ignored",
+			opts:        []Option{AllowedSources("10.0.0.0/8")},
+			remoteAddr:  "192.0.2.1:1234",
+			forwarded:   []string{"10.1.2.3"},
+			clientIP:    "192.0.2.1",
+			expectedErr: ErrSourceNotAllowed,
+		}, {
+			description: "forwarded by a trusted proxy",
+			opts: []Option{
+				AllowedSources("10.0.0.0/8"),
+				TrustedProxies("192.0.2.0/24"),
+			},
+			remoteAddr: "192.0.2.1:1234",
+			forwarded:  []string{"10.1.2.3"},
+		}, {
+			description: "forwarded by a chain of trusted proxies",
+			opts: []Option{
+				AllowedSources("10.0.0.0/8"),
+				TrustedProxies("192.0.2.0/24"),
+			},
+			remoteAddr: "192.0.2.1:1234",
+			forwarded:  []string{"198.51.100.1, 10.1.2.3", "192.0.2.2"},
+		}, {
+			description: "spoofed address before an untrusted hop",
+			opts: []Option{
+				AllowedSources("10.0.0.0/8"),
+				TrustedProxies("192.0.2.0/24"),
+			},
+			remoteAddr:  "192.0.2.1:1234",
+			forwarded:   []string{"10.1.2.3, 198.51.100.1"},
+			clientIP:    "198.51.100.1",
+			expectedErr: ErrSourceNotAllowed,
+		}, {
+			description: "invalid forwarded address",
+			opts: []Option{
+				AllowedSources("10.0.0.0/8"),
+				TrustedProxies("192.0.2.0/24"),
+			},
+			remoteAddr:  "192.0.2.1:1234",
+			forwarded:   []string{"unknown"},
+			expectedErr: ErrSourceNotAllowed,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var events []event.Authorize
+			opts := append([]Option{
+				AcceptSHA256(),
+				AcceptedSecrets("secret"),
+				WithAuthorizeEventListener(event.AuthorizeFunc(
+					func(e event.Authorize) {
+						events = append(events, e)
+					}),
+				),
+			}, tc.opts...)
+
+			l, err := New("http://example.com", &validWHR, opts...)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body"))
+			r.RemoteAddr = tc.remoteAddr
+			for _, f := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+
+			err = l.AuthorizeSource(r)
+			if tc.expectedErr == nil {
+				assert.NoError(err)
+				assert.Empty(events)
+				return
+			}
+
+			assert.ErrorIs(err, tc.expectedErr)
+			require.Len(events, 1)
+			assert.ErrorIs(events[0].Err, tc.expectedErr)
+			assert.Equal(tc.clientIP, events[0].ClientIP)
+
+			// Authorize rejects the source before checking the signature.
+			events = nil
+			err = l.Authorize(r, NewSignature("sha256", "invalid"))
+			assert.ErrorIs(err, tc.expectedErr)
+			assert.NotErrorIs(err, ErrInvalidSignature)
+			require.Len(events, 1)
+		})
+	}
+}
+
+func TestAuthorizeSource_NilRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	l, err := New("http://example.com", &validWHR,
+		AcceptSHA256(),
+		AcceptedSecrets("secret"),
+		AllowedSources("10.0.0.0/8"),
+	)
+	require.NoError(err)
+
+	assert.NotPanics(func() {
+		err = l.AuthorizeSource(nil)
+	})
+	assert.ErrorIs(err, ErrSourceNotAllowed)
+
+	assert.NotPanics(func() {
+		err = l.Authorize(nil, nil)
+	})
+	assert.ErrorIs(err, ErrSourceNotAllowed)
+}
+
+func TestSourcesOptions(t *testing.T) {
+	tests := []newTest{
+		{
+			description: "assert the ranges are parsed",
+			r:           validWHR,
+			opts: []Option{
+				AllowedSources("10.0.0.1/8", "2001:db8::1"),
+				AllowedSources("::ffff:192.0.2.1"),
+				TrustedProxies("192.0.2.0/24"),
+			},
+			check: func(assert *assert.Assertions, l *Listener) {
+				assert.Len(l.allowedSources, 3)
+				assert.Equal("10.0.0.0/8", l.allowedSources[0].String())
+				assert.Equal("2001:db8::1/128", l.allowedSources[1].String())
+				assert.Equal("192.0.2.1/32", l.allowedSources[2].String())
+				assert.Equal("192.0.2.0/24", l.trustedProxies[0].String())
+			},
+		}, {
+			description: "assert no ranges errors",
+			r:           validWHR,
+			opt:         AllowedSources(),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid range errors",
+			r:           validWHR,
+			opt:         AllowedSources("10.0.0.0/33"),
+			expectedErr: ErrInput,
+		}, {
+			description: "assert an invalid address errors",
+			r:           validWHR,
+			opt:         TrustedProxies("proxy"),
+			expectedErr: ErrInput,
+		},
+	}
+	commonNewTest(t, tests)
+
+	assert.Equal(t, "AllowedSources(10.0.0.0/8, 10.1.2.3)", AllowedSources("10.0.0.0/8", "10.1.2.3").String())
+	assert.Equal(t, "TrustedProxies(192.0.2.0/24)", TrustedProxies("192.0.2.0/24").String())
+}
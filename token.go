@@ -3,6 +3,12 @@
 
 package listener
 
+import (
+	"net/http"
+
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
 // Token represents the information needed to authenticate the flow of incoming
 // webhook callbacks.
 type Token interface {
@@ -10,26 +16,104 @@ type Token interface {
 	Principal() string
 }
 
-type token struct {
-	alg       string
-	principal string
+// Signature is the Token returned by the default tokenizer.  It can be stored,
+// compared or created directly when the token comes from somewhere other than
+// the request headers.
+type Signature struct {
+	// Algorithm is the algorithm used to create the signature, for example
+	// "sha256" or "ed25519".
+	Algorithm string
+
+	// Value is the encoded signature included with the callback.
+	Value string
+
+	// Alternatives are any other signatures offered with the same
+	// algorithm.  Each is tried if Value does not match, which allows the
+	// sender to sign with both the old and new secret during a rotation.
+	Alternatives []string
+}
+
+var _ Token = Signature{}
+
+// NewSignature creates a new Signature with the given algorithm and value.
+func NewSignature(alg, value string) Signature {
+	return Signature{
+		Algorithm: alg,
+		Value:     value,
+	}
+}
+
+// NewCertificateSignature creates a new Signature indicating the callback is
+// authorized by the client certificate alone.
+func NewCertificateSignature() Signature {
+	return NewSignature(CertificateAlgorithm, "")
 }
 
 // Type returns the type of hash to use for authentication.
-func (t token) Type() string {
-	return t.alg
+func (s Signature) Type() string {
+	return s.Algorithm
 }
 
 // Principal returns the principal (calculated value included with the message)
 // to use for authentication.
-func (t token) Principal() string {
-	return t.principal
+func (s Signature) Principal() string {
+	return s.Value
 }
 
-// newToken creates a new token with the given hash type and principal.
-func newToken(alg, principal string) *token {
-	return &token{
-		alg:       alg,
-		principal: principal,
+// String returns the algorithm of the token without the signature so the
+// token is safe to log.
+func (s Signature) String() string {
+	return "Signature(" + s.Algorithm + ")"
+}
+
+// TokenizerFunc parses the token from a webhook callback.  The Header,
+// Algorithms and Algorithm fields of the event should be set when applicable;
+// the Algorithm is filled in from the token if left empty.
+type TokenizerFunc func(r *http.Request, e *event.Tokenize) (Token, error)
+
+// WithTokenizer is an option that replaces how the token is found in the
+// webhook callbacks, for example to read the signature from a query parameter
+// or a differently named header used by a legacy sender.  The token is still
+// verified by the Verifier.  A nil TokenizerFunc restores the tokenizer of
+// the Verifier.
+func WithTokenizer(f TokenizerFunc) Option {
+	return &withTokenizerOption{
+		f: f,
+	}
+}
+
+type withTokenizerOption struct {
+	f TokenizerFunc
+}
+
+func (w withTokenizerOption) apply(lis *Listener) error {
+	lis.tokenizer = w.f
+	return nil
+}
+
+func (w withTokenizerOption) String() string {
+	if w.f == nil {
+		return "WithTokenizer(nil)"
+	}
+	return "WithTokenizer(func)"
+}
+
+// tokenize parses the token using the custom tokenizer if one is configured,
+// otherwise the Verifier.
+func (l *Listener) tokenize(r *http.Request, e *event.Tokenize) (Token, error) {
+	if l.tokenizer == nil {
+		return l.verifier.Tokenize(r, e)
+	}
+
+	t, err := l.tokenizer(r, e)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNoToken
+	}
+	if e.Algorithm == "" {
+		e.Algorithm = t.Type()
 	}
+	return t, nil
 }
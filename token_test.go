@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+func TestSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	var tok Token = NewSignature("sha256", "abcd")
+	assert.Equal("sha256", tok.Type())
+	assert.Equal("abcd", tok.Principal())
+	assert.Equal(Signature{Algorithm: "sha256", Value: "abcd"}, tok)
+	assert.Equal("Signature(sha256)", NewSignature("sha256", "abcd").String())
+
+	cert := NewCertificateSignature()
+	assert.Equal(CertificateAlgorithm, cert.Type())
+	assert.Empty(cert.Principal())
+}
+
+// queryTokenizer reads the sha256 signature from the sig query parameter.
+func queryTokenizer(r *http.Request, e *event.Tokenize) (Token, error) {
+	sig := r.URL.Query().Get("sig")
+	if sig == "" {
+		return nil, ErrNoToken
+	}
+	return NewSignature("sha256", sig), nil
+}
+
+func TestWithTokenizer(t *testing.T) {
+	const body = "body"
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		description string
+		tokenizer   TokenizerFunc
+		target      string
+		tokenErr    error
+		expectedErr error
+	}{
+		{
+			description: "signature from the query",
+			tokenizer:   queryTokenizer,
+			target:      "/?sig=" + sig,
+		}, {
+			description: "invalid signature from the query",
+			tokenizer:   queryTokenizer,
+			target:      "/?sig=abcd",
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "missing signature",
+			tokenizer:   queryTokenizer,
+			target:      "/",
+			tokenErr:    ErrNoToken,
+		}, {
+			description: "nil token",
+			tokenizer: func(*http.Request, *event.Tokenize) (Token, error) {
+				return nil, nil
+			},
+			target:   "/",
+			tokenErr: ErrNoToken,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var tokenizeEvents []event.Tokenize
+			l, err := New("http://example.com", &validWHR,
+				AcceptSHA256(),
+				AcceptedSecrets("secret"),
+				WithTokenizer(tc.tokenizer),
+				WithTokenizeEventListener(event.TokenizeFunc(
+					func(e event.Tokenize) {
+						tokenizeEvents = append(tokenizeEvents, e)
+					}),
+				),
+			)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(body))
+			r.Header.Set(xmidtHeader, "sha256=ignored")
+
+			token, err := l.Tokenize(r)
+			require.Len(tokenizeEvents, 1)
+			if tc.tokenErr != nil {
+				assert.ErrorIs(err, tc.tokenErr)
+				assert.ErrorIs(tokenizeEvents[0].Err, tc.tokenErr)
+				assert.Nil(token)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("sha256", tokenizeEvents[0].Algorithm)
+
+			match, err := l.AuthorizeMatch(r, token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(match)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("sha256", match.Algorithm)
+		})
+	}
+}
+
+func TestWithTokenizer_Default(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := New("http://example.com", &validWHR,
+		AcceptSHA256(),
+		WithTokenizer(queryTokenizer),
+		WithTokenizer(nil),
+	)
+	assert.NoError(err)
+	assert.Nil(l.tokenizer)
+
+	assert.Equal("WithTokenizer(func)", WithTokenizer(queryTokenizer).String())
+	assert.Equal("WithTokenizer(nil)", WithTokenizer(nil).String())
+}
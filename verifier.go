@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// A Verifier implements the scheme used to verify the webhook callbacks.  The
+// Listener takes care of the event reporting, checking the source address and
+// reading the request body, so a Verifier only deals with the token.
+//
+// The default Verifier uses the Xmidt-Signature or X-Webpa-Signature header
+// containing the hex, base64 or base64url encoded HMAC of the body, using the
+// accepted hashes and secrets of the Listener.  Multiple comma separated
+// signatures may be offered, and every signature using the preferred algorithm
+// is tried.  Signatures made with a private key are verified using the keys
+// from AcceptPublicKeys() or AcceptJWKSFile().  Client certificates are
+// checked as well if AcceptClientCertificates() is used.
+type Verifier interface {
+	// Tokenize parses the token from the request.  The Header, Algorithms
+	// and Algorithm fields of the event should be set when applicable.
+	Tokenize(r *http.Request, e *event.Tokenize) (Token, error)
+
+	// Verify validates the request and its body using the token.  The
+	// Algorithm, SecretIndex, SecretLabel, ClientCertificate and KeyID fields
+	// of the event should be set when applicable.  The SecretIndex is preset
+	// to -1, which indicates no secret matched.
+	Verify(r *http.Request, t Token, body []byte, e *event.Authorize) error
+}
+
+// WithVerifier is an option that replaces the scheme used to verify the
+// webhook callbacks.  A nil Verifier restores the default scheme.  The
+// accepted hashes and secrets are only used by the default scheme.
+func WithVerifier(v Verifier) Option {
+	return &withVerifierOption{
+		v: v,
+	}
+}
+
+type withVerifierOption struct {
+	v Verifier
+}
+
+func (w withVerifierOption) apply(lis *Listener) error {
+	lis.verifier = w.v
+	return nil
+}
+
+func (w withVerifierOption) String() string {
+	if w.v == nil {
+		return "WithVerifier(nil)"
+	}
+	return "WithVerifier(verifier)"
+}
+
+// signatureVerifier is the default Verifier.  It uses the accepted hashes,
+// secrets and client certificate policy of the Listener.
+type signatureVerifier struct {
+	l *Listener
+}
+
+func (v *signatureVerifier) Tokenize(r *http.Request, e *event.Tokenize) (Token, error) {
+	l := v.l
+
+	e.Header = xmidtHeader
+	headers := r.Header.Values(xmidtHeader)
+	if len(headers) == 0 {
+		headers = r.Header.Values(webpaHeader)
+		e.Header = webpaHeader
+	}
+
+	if len(headers) == 0 {
+		e.Header = ""
+	}
+
+	choices := map[string][]string{}
+	list := make([]string, 0, len(headers)+1)
+	list = append(list, "none")
+
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			alg, val, found := strings.Cut(part, "=")
+			alg = strings.ToLower(strings.TrimSpace(alg))
+			val = strings.TrimSpace(val)
+
+			// The value may be base64 encoded, but padding alone is not a
+			// signature.
+			if !found || alg == "" || strings.Trim(val, "=") == "" {
+				return nil, errors.Join(ErrInvalidTokenHeader, ErrInvalidHeaderFormat)
+			}
+
+			if _, seen := choices[alg]; !seen {
+				list = append(list, alg)
+			}
+			choices[alg] = append(choices[alg], val)
+		}
+	}
+
+	e.Algorithms = list
+
+	// Without a signature, the client certificate may be sufficient.
+	if len(list) == 1 && l.certPolicy != nil && !l.certPolicy.RequireSignature {
+		e.Algorithm = CertificateAlgorithm
+		return NewCertificateSignature(), nil
+	}
+
+	best, err := l.best(list)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTokenHeader, ErrAlgorithmNotFound)
+	}
+
+	e.Algorithm = best
+	if len(choices[best]) == 0 {
+		return NewSignature(best, ""), nil
+	}
+
+	t := NewSignature(best, choices[best][0])
+	t.Alternatives = choices[best][1:]
+	return t, nil
+}
+
+func (v *signatureVerifier) Verify(r *http.Request, t Token, body []byte, e *event.Authorize) error {
+	l := v.l
+
+	if t.Type() == CertificateAlgorithm && l.certPolicy != nil && !l.certPolicy.RequireSignature {
+		var err error
+		e.Algorithm = CertificateAlgorithm
+		e.ClientCertificate, err = l.certPolicy.allows(r)
+		return err
+	}
+
+	values := []string{t.Principal()}
+	if sig, ok := t.(Signature); ok {
+		values = append(values, sig.Alternatives...)
+	}
+
+	if t.Type() == Ed25519Algorithm || t.Type() == ES256Algorithm {
+		e.Algorithm = t.Type()
+		err := ErrInvalidSignature
+		for _, value := range values {
+			e.KeyID, err = l.publicKeys.verify(e.Algorithm, value, body)
+			if err == nil {
+				return v.requireCertificate(r, e)
+			}
+		}
+		return err
+	}
+
+	size := l.hashSize(t.Type())
+	sigs := make([][]byte, 0, len(values))
+	var decodeErr error
+	for _, value := range values {
+		sig, err := decodeSignature(value, size)
+		if err != nil {
+			decodeErr = err
+			continue
+		}
+		sigs = append(sigs, sig)
+	}
+	if len(sigs) == 0 {
+		return errors.Join(decodeErr, ErrInvalidSignature)
+	}
+
+	e.Algorithm = t.Type()
+	macs, secrets, labels, err := l.getMACs(e.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	var buf [64]byte
+	for i, mac := range macs {
+		sum := mac.sum(buf[:0], body)
+		for _, sig := range sigs {
+			if !hmac.Equal(sum, sig) {
+				continue
+			}
+
+			e.SecretIndex = i
+			e.SecretLabel = labels[i]
+			if l.fingerprints {
+				e.SecretFingerprint = fingerprint(secrets[i])
+			}
+			return v.requireCertificate(r, e)
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// signatureEncodings are the encodings tried after hex when decoding a
+// signature.
+var signatureEncodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// decodeSignature decodes a hex, base64 or base64url encoded signature.  The
+// size is the expected length of the signature for the algorithm, which
+// resolves values that are valid in more than one encoding.  If no encoding
+// produces the expected size, the first successful decoding is returned so the
+// signature fails to match rather than to decode.  A size of 0 accepts any
+// length.
+func decodeSignature(s string, size int) ([]byte, error) {
+	var fallback []byte
+
+	b, err := hex.DecodeString(s)
+	if err == nil {
+		if size == 0 || len(b) == size {
+			return b, nil
+		}
+		fallback = b
+	}
+
+	for _, enc := range signatureEncodings {
+		b, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		if size == 0 || len(b) == size {
+			return b, nil
+		}
+		if fallback == nil {
+			fallback = b
+		}
+	}
+
+	if fallback != nil {
+		return fallback, nil
+	}
+
+	return nil, fmt.Errorf("%w: unable to decode the signature", ErrInvalidSignature)
+}
+
+// requireCertificate checks the client certificate of a request with a valid
+// signature if the policy requires both.
+func (v *signatureVerifier) requireCertificate(r *http.Request, e *event.Authorize) error {
+	p := v.l.certPolicy
+	if p == nil || !p.RequireSignature {
+		return nil
+	}
+
+	var err error
+	e.ClientCertificate, err = p.allows(r)
+	return err
+}
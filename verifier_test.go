@@ -0,0 +1,329 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package listener
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-listener/event"
+)
+
+// prefixVerifier is a toy scheme where the X-Prefix header must match the
+// start of the body.
+type prefixVerifier struct{}
+
+func (prefixVerifier) Tokenize(r *http.Request, e *event.Tokenize) (Token, error) {
+	e.Header = "X-Prefix"
+	prefix := r.Header.Get("X-Prefix")
+	if prefix == "" {
+		return nil, ErrNoToken
+	}
+	e.Algorithm = "prefix"
+	return NewSignature("prefix", prefix), nil
+}
+
+func (prefixVerifier) Verify(_ *http.Request, t Token, body []byte, e *event.Authorize) error {
+	e.Algorithm = t.Type()
+	if !strings.HasPrefix(string(body), t.Principal()) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func TestWithVerifier(t *testing.T) {
+	tests := []struct {
+		description string
+		header      string
+		tokenErr    error
+		expectedErr error
+	}{
+		{
+			description: "valid",
+			header:      "hello",
+		}, {
+			description: "missing header",
+			tokenErr:    ErrNoToken,
+		}, {
+			description: "invalid",
+			header:      "goodbye",
+			expectedErr: ErrInvalidSignature,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var tokenizeEvents []event.Tokenize
+			var authorizeEvents []event.Authorize
+			l, err := New("http://example.com", &validWHR,
+				WithVerifier(prefixVerifier{}),
+				WithTokenizeEventListener(event.TokenizeFunc(
+					func(e event.Tokenize) {
+						tokenizeEvents = append(tokenizeEvents, e)
+					}),
+				),
+				WithAuthorizeEventListener(event.AuthorizeFunc(
+					func(e event.Authorize) {
+						authorizeEvents = append(authorizeEvents, e)
+					}),
+				),
+			)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("hello world"))
+			if tc.header != "" {
+				r.Header.Set("X-Prefix", tc.header)
+			}
+
+			token, err := l.Tokenize(r)
+			require.Len(tokenizeEvents, 1)
+			assert.Equal("X-Prefix", tokenizeEvents[0].Header)
+			assert.Equal("/path", tokenizeEvents[0].Path)
+			assert.NotZero(tokenizeEvents[0].At)
+			if tc.tokenErr != nil {
+				assert.ErrorIs(err, tc.tokenErr)
+				assert.ErrorIs(tokenizeEvents[0].Err, tc.tokenErr)
+				assert.Nil(token)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("prefix", tokenizeEvents[0].Algorithm)
+
+			match, err := l.AuthorizeMatch(r, token)
+			require.Len(authorizeEvents, 1)
+			assert.Equal("prefix", authorizeEvents[0].Algorithm)
+			assert.Equal(-1, authorizeEvents[0].SecretIndex)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.ErrorIs(authorizeEvents[0].Err, tc.expectedErr)
+				assert.Nil(match)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("prefix", match.Algorithm)
+		})
+	}
+}
+
+func TestWithVerifier_Default(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := New("http://example.com", &validWHR,
+		AcceptSHA256(),
+		WithVerifier(prefixVerifier{}),
+		WithVerifier(nil),
+	)
+	assert.NoError(err)
+	assert.IsType(&signatureVerifier{}, l.verifier)
+
+	assert.Equal("WithVerifier(verifier)", WithVerifier(prefixVerifier{}).String())
+	assert.Equal("WithVerifier(nil)", WithVerifier(nil).String())
+}
+
+func TestSignatureVerifier_MultipleSignatures(t *testing.T) {
+	const body = "body"
+	sign := func(h func() hash.Hash, secret string) []byte {
+		mac := hmac.New(h, []byte(secret))
+		mac.Write([]byte(body))
+		return mac.Sum(nil)
+	}
+	sha1Sig := sign(sha1.New, "secret")
+	sha256Sig := sign(sha256.New, "secret")
+	oldSig := sign(sha256.New, "old")
+
+	tests := []struct {
+		description string
+		headers     []string
+		algorithms  []string
+		alg         string
+		tokenErr    error
+		expectedErr error
+	}{
+		{
+			description: "comma separated",
+			headers:     []string{"sha1=" + hex.EncodeToString(sha1Sig) + ", sha256=" + hex.EncodeToString(sha256Sig)},
+			algorithms:  []string{"none", "sha1", "sha256"},
+			alg:         "sha256",
+		}, {
+			description: "multiple headers",
+			headers: []string{
+				"sha1=" + hex.EncodeToString(sha1Sig),
+				"sha256=" + hex.EncodeToString(sha256Sig),
+			},
+			algorithms: []string{"none", "sha1", "sha256"},
+			alg:        "sha256",
+		}, {
+			description: "every signature of the preferred algorithm is tried",
+			headers: []string{
+				"sha256=" + hex.EncodeToString(oldSig) + ",sha256=" + hex.EncodeToString(sha256Sig),
+				"sha256=" + hex.EncodeToString(oldSig),
+			},
+			algorithms: []string{"none", "sha256"},
+			alg:        "sha256",
+		}, {
+			description: "base64",
+			headers:     []string{"sha256=" + base64.StdEncoding.EncodeToString(sha256Sig)},
+			algorithms:  []string{"none", "sha256"},
+			alg:         "sha256",
+		}, {
+			description: "unpadded base64url",
+			headers:     []string{"SHA1=" + base64.RawURLEncoding.EncodeToString(sha1Sig)},
+			algorithms:  []string{"none", "sha1"},
+			alg:         "sha1",
+		}, {
+			description: "no signature matches",
+			headers:     []string{"sha256=" + hex.EncodeToString(oldSig) + ", sha1=" + hex.EncodeToString(sha1Sig)},
+			algorithms:  []string{"none", "sha256", "sha1"},
+			alg:         "sha256",
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "undecodable signature",
+			headers:     []string{"sha256=!!!"},
+			algorithms:  []string{"none", "sha256"},
+			alg:         "sha256",
+			expectedErr: ErrInvalidSignature,
+		}, {
+			description: "padding only",
+			headers:     []string{"sha256=" + hex.EncodeToString(sha256Sig) + ", sha1=="},
+			tokenErr:    ErrInvalidHeaderFormat,
+		}, {
+			description: "missing separator",
+			headers:     []string{"sha256=" + hex.EncodeToString(sha256Sig) + ", sha1"},
+			tokenErr:    ErrInvalidHeaderFormat,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var tokenizeEvents []event.Tokenize
+			l, err := New("http://example.com", &validWHR,
+				AcceptSHA256(),
+				AcceptSHA1(),
+				AcceptedSecrets("secret"),
+				WithTokenizeEventListener(event.TokenizeFunc(
+					func(e event.Tokenize) {
+						tokenizeEvents = append(tokenizeEvents, e)
+					}),
+				),
+			)
+			require.NoError(err)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			for _, h := range tc.headers {
+				r.Header.Add(xmidtHeader, h)
+			}
+
+			token, err := l.Tokenize(r)
+			require.Len(tokenizeEvents, 1)
+			if tc.tokenErr != nil {
+				assert.ErrorIs(err, tc.tokenErr)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.algorithms, tokenizeEvents[0].Algorithms)
+			assert.Equal(tc.alg, token.Type())
+
+			match, err := l.AuthorizeMatch(r, token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.alg, match.Algorithm)
+		})
+	}
+}
+
+func TestDecodeSignature(t *testing.T) {
+	raw := []byte(strings.Repeat("\xde\xad\xbe\xef", 8))
+
+	tests := []struct {
+		description string
+		in          string
+		size        int
+		expected    []byte
+		expectedErr error
+	}{
+		{
+			description: "hex",
+			in:          hex.EncodeToString(raw),
+			size:        32,
+			expected:    raw,
+		}, {
+			description: "base64",
+			in:          base64.StdEncoding.EncodeToString(raw),
+			size:        32,
+			expected:    raw,
+		}, {
+			description: "unpadded base64",
+			in:          base64.RawStdEncoding.EncodeToString(raw),
+			size:        32,
+			expected:    raw,
+		}, {
+			description: "base64url",
+			in:          base64.URLEncoding.EncodeToString([]byte(strings.Repeat("\xff\xfe", 16))),
+			size:        32,
+			expected:    []byte(strings.Repeat("\xff\xfe", 16)),
+		}, {
+			description: "any size prefers hex",
+			in:          "abcd",
+			expected:    []byte{0xab, 0xcd},
+		}, {
+			description: "wrong size falls back to hex",
+			in:          "abcd",
+			size:        32,
+			expected:    []byte{0xab, 0xcd},
+		}, {
+			description: "invalid",
+			in:          "!!!",
+			size:        32,
+			expectedErr: ErrInvalidSignature,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := decodeSignature(tc.in, tc.size)
+			assert.ErrorIs(err, tc.expectedErr)
+			assert.Equal(tc.expected, got)
+		})
+	}
+}
+
+func TestSignatureVerifier_NoHash(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	l, err := New("http://example.com", &validWHR,
+		AcceptNoHash(),
+		AcceptedSecrets("secret"),
+	)
+	require.NoError(err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body"))
+
+	token, err := l.Tokenize(r)
+	require.NoError(err)
+	assert.Equal("none", token.Type())
+
+	assert.NotPanics(func() {
+		err = l.Authorize(r, token)
+	})
+	assert.ErrorIs(err, ErrInvalidSignature)
+}